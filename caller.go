@@ -1,17 +1,112 @@
 package lcf
 
 import (
+	"bytes"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
+
+	"github.com/sirupsen/logrus"
 )
 
+// Import path of this package. Its frames are skipped when looking for the caller that logged an entry.
+var lcfPackage = reflect.TypeOf(CustomFormatter{}).PkgPath()
+
 // CallerName returns the name of the calling function using the runtime package. Empty string if something fails.
 //
 // :param skip: Skip these many calls in the stack.
 func CallerName(skip int) string {
 	if pc, _, _, ok := runtime.Caller(skip); ok {
-		split := strings.Split(runtime.FuncForPC(pc).Name(), ".")
-		return split[len(split)-1]
+		return funcName(runtime.FuncForPC(pc).Name())
 	}
 	return ""
 }
+
+// funcName returns the last element of a function's full name (e.g. "Info" from
+// "github.com/sirupsen/logrus.(*Entry).Info").
+func funcName(function string) string {
+	return function[strings.LastIndexByte(function, '.')+1:]
+}
+
+// packagePath returns the import path of a function's package (e.g. "github.com/sirupsen/logrus" from
+// "github.com/sirupsen/logrus.(*Entry).Info").
+func packagePath(function string) string {
+	lastSlash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[lastSlash+1:], '.'); dot >= 0 {
+		return function[:lastSlash+1+dot]
+	}
+	return function
+}
+
+// isLogrusPackage returns true if the import path is logrus' (including vendored copies and the old capitalized name).
+func isLogrusPackage(path string) bool {
+	const logrusPath = "github.com/sirupsen/logrus"
+	return len(path) >= len(logrusPath) && strings.EqualFold(path[len(path)-len(logrusPath):], logrusPath)
+}
+
+// callerFrame returns the stack frame of the code that logged the entry. Uses entry.Caller if logrus reports callers,
+// otherwise walks the stack to the first frame after logrus' own frames. If logrus is not in the stack (e.g. Format()
+// was called directly) the first frame outside of this package is returned.
+func callerFrame(entry *logrus.Entry) (runtime.Frame, bool) {
+	if entry != nil && entry.Caller != nil {
+		return *entry.Caller, true
+	}
+
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	var fallback runtime.Frame
+	found, seenLogrus := false, false
+	for {
+		frame, more := frames.Next()
+		path := packagePath(frame.Function)
+		switch {
+		case isLogrusPackage(path):
+			seenLogrus = true
+		case seenLogrus:
+			return frame, true
+		case !found && (path != lcfPackage || strings.HasSuffix(frame.File, "_test.go")):
+			fallback, found = frame, true
+		}
+		if !more {
+			return fallback, found
+		}
+	}
+}
+
+// caller returns the stack frame of the code that logged the entry being rendered. It is looked up once per entry.
+func (s *renderState) caller(entry *logrus.Entry) (runtime.Frame, bool) {
+	if !s.callerDone {
+		s.callerFrame, s.callerFound = callerFrame(entry)
+		s.callerDone = true
+	}
+	return s.callerFrame, s.callerFound
+}
+
+// callerModule returns the last element of the caller's package import path (e.g. "main").
+func callerModule(frame runtime.Frame) string {
+	path := packagePath(frame.Function)
+	return path[strings.LastIndexByte(path, '/')+1:]
+}
+
+// callerFileName returns the base name of the caller's source file. Empty if the caller is unknown.
+func callerFileName(frame runtime.Frame) string {
+	if frame.File == "" {
+		return ""
+	}
+	return filepath.Base(frame.File)
+}
+
+// goroutineID returns the ID of the current goroutine parsed from the first line of its stack trace (e.g. "goroutine 7
+// [running]:"). Returns 0 if something fails.
+func goroutineID() int {
+	var buf [64]byte
+	line := buf[:runtime.Stack(buf[:], false)]
+	line = bytes.TrimPrefix(line, []byte("goroutine "))
+	if i := bytes.IndexByte(line, ' '); i > 0 {
+		line = line[:i]
+	}
+	id, _ := strconv.Atoi(string(line))
+	return id
+}
@@ -1,8 +1,13 @@
 package lcf
 
 import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 )
 
@@ -12,3 +17,79 @@ func TestCallerName(t *testing.T) {
 	assert.Equal("TestCallerName", CallerName(1))
 	assert.Equal("TestCallerName", func() string { return CallerName(2) }())
 }
+
+func TestGoroutineID(t *testing.T) {
+	assert := require.New(t)
+
+	id := goroutineID()
+	assert.True(id > 0)
+	assert.Equal(id, goroutineID())
+
+	other := make(chan int)
+	go func() { other <- goroutineID() }()
+	assert.NotEqual(id, <-other)
+}
+
+func TestCallerAttributes(t *testing.T) {
+	template := "%[funcName]s %[fileName]s %[module]s %[lineNo]d %[pathName]s"
+
+	for _, reportCaller := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reportCaller=%v", reportCaller), func(t *testing.T) {
+			assert := require.New(t)
+			var buffer bytes.Buffer
+			logger := logrus.New()
+			logger.Out = &buffer
+			logger.Formatter = NewFormatter(template, nil)
+			logger.SetReportCaller(reportCaller)
+
+			_, file, line, _ := runtime.Caller(0)
+			logger.Info("Sample info.")
+			module := lcfPackage[strings.LastIndexByte(lcfPackage, '/')+1:]
+			expected := fmt.Sprintf("func1 caller_test.go %s %d %s", module, line+1, file)
+			assert.Equal(expected, buffer.String())
+
+			// Handlers find the same caller when called through fmt.
+			buffer.Reset()
+			logger.Formatter = NewFormatter("%[funcName]q %5[lineNo]x", nil)
+			logger.Warn("Sample warn.")
+			assert.Equal(fmt.Sprintf(`"func1" %5x`, line+9), buffer.String())
+		})
+	}
+}
+
+func TestCallerFrame(t *testing.T) {
+	assert := require.New(t)
+
+	// Without logrus in the stack the first frame outside of lcf's non-test files is used.
+	frame, ok := callerFrame(&logrus.Entry{})
+	assert.True(ok)
+	assert.Equal("TestCallerFrame", funcName(frame.Function))
+
+	// entry.Caller is used when set.
+	caller := runtime.Frame{Function: "github.com/example/app/pkg.(*Server).Serve", File: "/src/app/pkg/server.go"}
+	frame, ok = callerFrame(&logrus.Entry{Caller: &caller})
+	assert.True(ok)
+	assert.Equal("Serve", funcName(frame.Function))
+	assert.Equal("pkg", callerModule(frame))
+	assert.Equal("server.go", callerFileName(frame))
+}
+
+func TestPackagePath(t *testing.T) {
+	testCases := map[string]string{
+		"main.main": "main",
+		"github.com/sirupsen/logrus.(*Entry).Info":  "github.com/sirupsen/logrus",
+		"github.com/Sirupsen/logrus.Info":           "github.com/Sirupsen/logrus",
+		"github.com/example/app/pkg.handler.func1":  "github.com/example/app/pkg",
+		"github.com/example/vendor/pkg.(*T).Method": "github.com/example/vendor/pkg",
+	}
+	for function, expected := range testCases {
+		t.Run(function, func(t *testing.T) {
+			assert := require.New(t)
+			assert.Equal(expected, packagePath(function))
+		})
+	}
+	assert := require.New(t)
+	assert.True(isLogrusPackage("github.com/Sirupsen/logrus"))
+	assert.True(isLogrusPackage("example.com/app/vendor/github.com/sirupsen/logrus"))
+	assert.False(isLogrusPackage("github.com/sirupsen/logrus/hooks/test"))
+}
@@ -1,18 +1,21 @@
 package lcf
 
 import (
-	"fmt"
-	"regexp"
-	"strconv"
+	"io"
+	"os"
+	"runtime"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
 )
 
 // ANSI color codes.
 const (
 	AnsiReset     = 0
+	AnsiBlack     = 30
+	AnsiHiBlack   = 90
 	AnsiRed       = 31
 	AnsiHiRed     = 91
 	AnsiGreen     = 32
@@ -29,106 +32,163 @@ const (
 	AnsiHiWhite   = 97
 )
 
-var _reAnsi = regexp.MustCompile("\033\\[[\\d;]+m")
-
-// From fmt.Sprintf()'s source code. Only handle one format (%2s) for one value. `a` width handled by caller.
-func sprintfColorString(format, a string, w int) string {
-	pos := 1 // format should always start with %.
-	minus := false
-	buffer := []byte{}
-	defer func() { buffer = buffer[:0] }()
-
-	// Handle '-' character after %.
-	if format[pos] == '-' {
-		minus = true
-		pos++
-	}
+// hasEscape returns true if s contains an escape character, which starts ANSI color sequences.
+func hasEscape(s string) bool {
+	return strings.IndexByte(s, '\033') >= 0
+}
 
-	// Parse padding/width.
-	width := 0
-	for '0' <= format[pos] && format[pos] <= '9' {
-		width = width*10 + int(format[pos]-'0')
-		pos++
+// ansiSequenceLen returns the length of the ANSI color sequence (e.g. "\033[31m") at the start of b. Returns 0 if b does
+// not start with one.
+func ansiSequenceLen(b []byte) int {
+	if len(b) < 4 || b[0] != '\033' || b[1] != '[' {
+		return 0
 	}
-	width -= w
-	if width <= 0 {
-		buffer = append(buffer, a...)
-		return string(buffer)
+	for i := 2; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == 'm' && i > 2:
+			return i + 1
+		case c != ';' && (c < '0' || c > '9'):
+			return 0
+		}
 	}
+	return 0
+}
 
-	// Add padding.
-	if minus {
-		buffer = append(buffer, a...)
-	}
-	padding := make([]byte, width)
-	for i := 0; i < width; i++ {
-		padding[i] = byte(' ')
-	}
-	buffer = append(buffer, padding...)
-	if !minus {
-		buffer = append(buffer, a...)
+// visibleWidth returns the number of terminal columns b takes excluding ANSI color sequences.
+func visibleWidth(b []byte) int {
+	return displayWidth(b, true)
+}
+
+// visibleRuneCount returns the number of runes in b excluding ANSI color sequences.
+func visibleRuneCount(b []byte) int {
+	count := 0
+	for i := 0; i < len(b); count++ {
+		for n := ansiSequenceLen(b[i:]); n > 0; n = ansiSequenceLen(b[i:]) {
+			i += n
+		}
+		if i == len(b) {
+			break
+		}
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
 	}
-	return string(buffer)
+	return count
 }
 
-// Sprintf is like fmt.Sprintf() but exclude ANSI color sequences from string padding.
+// Sprintf is like fmt.Sprintf() but exclude ANSI color sequences from string padding. Values are matched up with the
+// attributes in the parsed template in order.
 func (f *CustomFormatter) Sprintf(values ...interface{}) string {
-	if (!f.ForceColors && f.DisableColors) || len(f.handleColors) == 0 {
-		return fmt.Sprintf(f.Template, values...)
+	s := getRenderState()
+	defer putRenderState(s)
+	if f.compiled == nil {
+		return ""
 	}
-	template := f.Template
-	for i := len(f.handleColors) - 1; i >= 0; i-- {
-		value := values[f.handleColors[i][0]].(string)
-		pos := strings.Index(value, "\033")
-		if pos < 0 {
-			continue
+	i := 0
+	for _, st := range f.compiled.plan {
+		switch {
+		case st.group == groupStart:
+			s.openGroup()
+		case st.group == groupEnd:
+			s.closeGroup()
+		case st.node == nil:
+			s.buffer.WriteString(st.text)
+		case i < len(values) && st.filters != nil:
+			value, ok := values[i].(string)
+			if !ok {
+				start := s.buffer.Len()
+				appendValue(s, values[i])
+				value = s.buffer.String()[start:]
+				s.buffer.Truncate(start)
+			}
+			f.writeFilteredValue(s, &st, value)
+			i++
+		case i < len(values):
+			s.markValue(isEmptyValue(values[i]))
+			f.writeValue(s, st.node, values[i])
+			i++
+		default:
+			s.buffer.WriteString("%!" + string(st.node.Verb) + "(MISSING)")
 		}
+	}
+	return s.buffer.String()
+}
 
-		// Determine width without color sequences.
-		width := utf8.RuneCountInString(value)
-		for _, p := range _reAnsi.FindAllStringIndex(value[pos:], -1) {
-			width -= p[1] - p[0]
-		}
+// Color colorizes the input string and returns it with ANSI color codes.
+func Color(entry *logrus.Entry, formatter *CustomFormatter, s string) string {
+	return formatter.colorize(formatter.levelColor(entry.Level), s)
+}
 
-		// Pull formatting from template.
-		start, end := f.handleColors[i][1], f.handleColors[i][2]
-		format := template[start:end]
-		template = template[:start] + "%s" + template[end:]
+// colorize returns the text wrapped in the ANSI color codes of a style if colors are enabled.
+func (f *CustomFormatter) colorize(style Style, text string) string {
+	if !f.colorsEnabled() || style == AnsiReset || text == "" {
+		return text
+	}
+	return string(appendSGR(nil, style, f.Palette)) + text + "\033[0m"
+}
 
-		// Format value while not counting ANSI color codes (yet still including them).
-		values[f.handleColors[i][0]] = sprintfColorString(format, value, width)
+// writeStyle is like colorize() but writes to the render buffer.
+func (f *CustomFormatter) writeStyle(s *renderState, style Style, text string) {
+	if !f.colorsEnabled() || style == AnsiReset || text == "" {
+		s.buffer.WriteString(text)
+		return
 	}
-	return fmt.Sprintf(template, values...)
+	s.scratch = appendSGR(s.scratch[:0], style, f.Palette)
+	s.buffer.Write(s.scratch)
+	s.buffer.WriteString(text)
+	s.buffer.WriteString("\033[0m")
 }
 
-// Color colorizes the input string and returns it with ANSI color codes.
-func Color(entry *logrus.Entry, formatter *CustomFormatter, s string) string {
-	if !formatter.ForceColors && formatter.DisableColors {
-		return s
+// fieldKeyColor returns the color of field keys in entries of a log level.
+func (f *CustomFormatter) fieldKeyColor(level logrus.Level) Style {
+	if f.ColorFieldKey != AnsiReset {
+		return f.ColorFieldKey
 	}
+	return f.levelColor(level)
+}
 
-	// Determine color. Default is info.
-	var levelColor int
-	switch entry.Level {
-	case logrus.DebugLevel:
-		levelColor = formatter.ColorDebug
-	case logrus.WarnLevel:
-		levelColor = formatter.ColorWarn
-	case logrus.ErrorLevel:
-		levelColor = formatter.ColorError
-	case logrus.PanicLevel:
-		levelColor = formatter.ColorPanic
-	case logrus.FatalLevel:
-		levelColor = formatter.ColorFatal
+// envColors returns whether the environment enables or disables colors, following the NO_COLOR
+// (https://no-color.org), CLICOLOR/CLICOLOR_FORCE (https://bixense.com/clicolors/) and FORCE_COLOR conventions. In
+// order of precedence:
+//
+// NO_COLOR set to anything disables colors. CLICOLOR_FORCE or FORCE_COLOR set to anything but "0" (or "false" for
+// FORCE_COLOR) enables colors, while FORCE_COLOR=0 disables them. TERM=dumb and CLICOLOR=0 disable colors.
+//
+// ok is false if the environment does not decide.
+func envColors() (colors, ok bool) {
+	if os.Getenv("NO_COLOR") != "" {
+		return false, true
+	}
+	if value := os.Getenv("CLICOLOR_FORCE"); value != "" && value != "0" {
+		return true, true
+	}
+	switch value := os.Getenv("FORCE_COLOR"); value {
+	case "":
+	case "0", "false":
+		return false, true
 	default:
-		levelColor = formatter.ColorInfo
+		return true, true
+	}
+	if os.Getenv("TERM") == "dumb" || os.Getenv("CLICOLOR") == "0" {
+		return false, true
 	}
-	if levelColor == AnsiReset {
-		return s
+	return false, false
+}
+
+// colorsFor returns true if colors should be written to a writer: the environment's color settings (see envColors),
+// otherwise colors are written if the writer is a terminal that supports ANSI color codes.
+func colorsFor(w io.Writer) bool {
+	if colors, ok := envColors(); ok {
+		return colors
 	}
+	return isTerminal(w) && (runtime.GOOS != "windows" || WindowsNativeANSI())
+}
 
-	// Colorize.
-	return "\033[" + strconv.Itoa(levelColor) + "m" + s + "\033[0m"
+// isTerminal returns true if the writer is a file descriptor connected to a terminal.
+func isTerminal(w io.Writer) bool {
+	if file, ok := w.(*os.File); ok {
+		return term.IsTerminal(int(file.Fd()))
+	}
+	return false
 }
 
 // WindowsNativeANSI returns true if either the stderr or stdout consoles natively support ANSI color codes. On
@@ -32,6 +32,7 @@ func TestColor(t *testing.T) {
 	entry := logrus.NewEntry(logrus.New())
 
 	testCases := map[logrus.Level]string{
+		logrus.TraceLevel: "\033[90mTest\033[0m",
 		logrus.DebugLevel: "\033[36mTest\033[0m",
 		logrus.InfoLevel:  "\033[32mTest\033[0m",
 		logrus.WarnLevel:  "\033[33mTest\033[0m",
@@ -153,3 +154,50 @@ func TestCustomFormatter_SprintfColors(t *testing.T) {
 	assert.Equal("\033[31m\033[0m", formatter.Sprintf("\033[31m\033[0m"))
 	assert.Equal("\033[0m", formatter.Sprintf("\033[0m"))
 }
+
+func TestEnvColors(t *testing.T) {
+	testCases := []struct {
+		noColor, cliColor, cliColorForce, forceColor, term string
+		colors, ok                                         bool
+	}{
+		{"", "", "", "", "xterm", false, false},
+		{"1", "", "", "", "xterm", false, true},
+		{"1", "", "1", "1", "xterm", false, true},
+		{"", "", "1", "", "dumb", true, true},
+		{"", "", "0", "", "xterm", false, false},
+		{"", "", "", "true", "xterm", true, true},
+		{"", "", "", "3", "dumb", true, true},
+		{"", "", "", "0", "xterm", false, true},
+		{"", "", "", "false", "xterm", false, true},
+		{"", "", "", "", "dumb", false, true},
+		{"", "0", "", "", "xterm", false, true},
+		{"", "1", "", "", "xterm", false, false},
+		{"", "0", "1", "", "xterm", true, true},
+	}
+	for _, tc := range testCases {
+		name := fmt.Sprintf("NO_COLOR=%s CLICOLOR=%s CLICOLOR_FORCE=%s FORCE_COLOR=%s TERM=%s",
+			tc.noColor, tc.cliColor, tc.cliColorForce, tc.forceColor, tc.term)
+		t.Run(name, func(t *testing.T) {
+			assert := require.New(t)
+			defer setenv("NO_COLOR", tc.noColor)()
+			defer setenv("CLICOLOR", tc.cliColor)()
+			defer setenv("CLICOLOR_FORCE", tc.cliColorForce)()
+			defer setenv("FORCE_COLOR", tc.forceColor)()
+			defer setenv("TERM", tc.term)()
+
+			colors, ok := envColors()
+			assert.Equal(tc.colors, colors)
+			assert.Equal(tc.ok, ok)
+
+			// The environment only decides DisableColors. Tests do not write to a terminal.
+			formatter := NewFormatter(Basic, nil)
+			assert.False(formatter.ForceColors)
+			assert.Equal(!tc.colors, formatter.DisableColors)
+			assert.Equal(tc.colors, formatter.colorsEnabled())
+
+			// Code overrides the environment.
+			formatter.DisableColors = true
+			assert.False(formatter.colorsEnabled())
+		})
+	}
+}
@@ -51,21 +51,219 @@ Built-In Attributes
 These attributes are provided by lcf and can be specified in your template string:
 
 	%[ascTime]s		Timestamp formatted by CustomFormatter.TimestampFormat.
-	%[fields]s		Logrus fields formatted as "key1=value key2=value". Keys are
-				sorted unless CustomFormatter.DisableSorting is true.
+	%[created]f		Timestamp as seconds since the Unix epoch.
+	%[fields]s		Logrus fields formatted as logfmt ("key1=value key2="a b"").
+				Keys are sorted unless CustomFormatter.DisableSorting is
+				true. CustomFormatter.FieldQuoting selects logrus'
+				TextFormatter quoting instead.
+	%[fileName]s		Base name of the source file that logged the entry.
+	%[funcName]s		Name of the function that logged the entry.
+	%[goroutine]d		ID of the goroutine that logged the entry.
+	%[levelCode]s		Single letter code of the log level (e.g. I, W, E).
 	%[levelName]s		The capitalized log level name (e.g. INFO, WARNING, ERROR).
+	%[lineNo]d		Source line number that logged the entry.
 	%[message]s		The log message.
+	%[module]s		Last element of the logging package's import path.
+	%[msecs]d		Millisecond portion of the timestamp.
 	%[name]s		The value of the "name" field. If used "name" will be omitted
 				from %[fields]s.
+	%[pathName]s		Full path of the source file that logged the entry.
 	%[process]d		The current PID of the process emitting log statements.
 	%[relativeCreated]d	Number of seconds since the program has started (since
 				formatter was created)
+	%[relativeMsecs]d	Like %[relativeCreated]d but in milliseconds.
 	%[shortLevelName]s	Like %[levelName]s except WARNING is shown as "WARN".
 
+Any field can be shown in its own column with a field-reference attribute: %[field:request_id]s or its short form
+%[.request_id]s. Like %[name]s referenced fields are omitted from %[fields]s. Their values are colored with
+CustomFormatter.ColorFieldValue with any verb (e.g. %05[.status]d), and missing fields are written as padding only. In
+brace style templates only the short form is available (e.g. "{.request_id:<10}").
+
+Caller attributes (fileName, funcName, lineNo, module and pathName) use entry.Caller when the logger has
+SetReportCaller(true). Otherwise the stack is walked past logrus' and lcf's own frames to find the call site.
+
+Field values are written like fmt's %v verb, except that time.Time values are formatted by TimestampFormat, []byte
+values are written as hex and json.Marshalers as JSON. ValueRenderers for other field keys or Go types change this in
+%[fields]s and field-reference attributes:
+
+	formatter.FieldRenderers = map[string]lcf.ValueRenderer{"user": lcf.RenderJSON}
+	formatter.SetTypeRenderer([]byte(nil), lcf.RenderBytesBase64)
+	formatter.SetTypeRenderer(time.Duration(0), lcf.RenderDurationSeconds)
+
+Nested maps, structs (named after their json tags) and slices are expanded into dotted keys such as
+"http.method=GET user.id=42" when CustomFormatter.Flatten.Enabled is true. MaxDepth and MaxElements limit how much of
+large values is written, and values containing themselves are written as "<cycle>".
+
+CustomFormatter.FieldOrder shows the most important fields first and hides noisy ones:
+
+	formatter.FieldOrder = lcf.FieldOrder{
+		First:   []string{"request_id", "user"}, // Always at the start of %[fields]s.
+		Exclude: []string{"trace_context"},
+	}
+
+Include shows only the listed fields and Less orders the remaining keys instead of sorting them by key.
+
+Conditional Groups
+
+Text between "%{" and "%}" is omitted when every attribute inside it renders empty, so optional columns do not leave
+stray separators or padding behind. Groups without attributes are always shown and groups may be nested:
+
+	%[levelName]s:%{%[name]s:%}%[message]s
+	%{[%-10[name]s] %}%[message]s
+
+Filters
+
+Attribute values can be passed through a pipeline of filters separated by pipes. Filtered values are formatted as
+strings, with %s unless the verb is q, v, x or X, and ANSI color sequences in them are left intact:
+
+	%-20[name|trunc:20|default:main]s %[message|upper]s %[pathName|basename]s %[name|center:12]s
+
+	basename	Last element of a path.
+	center:N	Pad with spaces on both sides to N characters.
+	default:TEXT	Use TEXT if the value is empty.
+	lower		Lower case.
+	trunc:N		Keep the first N characters.
+	upper		Upper case.
+
+Widths are measured in terminal columns, so East Asian wide characters and emoji take two columns while combining marks
+take none. Like a precision (e.g. %-7.4[levelName]s) trunc only counts visible characters and keeps color sequences so
+colors are still reset. CustomFormatter.Ellipsis (e.g. "…") marks values cut by a precision; trunc takes its marker as
+a second argument (e.g. "trunc:20:…").
+
+More filters can be added with RegisterFilter. In brace style templates "^" alignment uses the center filter.
+
+Redaction
+
+CustomFormatter.Redact masks secrets before an entry is formatted, so built-in attributes and custom handlers reading
+entry.Data or entry.Message only see masked values. Values of fields with matching keys are replaced, either
+completely or keeping their last characters, and Scrubbers mask matches in the message and field values:
+
+	formatter.Redact = lcf.Redaction{
+		Keys:        []string{"password", "*_token", "authorization"},
+		PartialKeys: []lcf.KeyRule{{Pattern: "card_number", KeepLast: 4}}, // "****1234"
+		Scrubbers:   []lcf.Scrubber{lcf.ScrubCreditCards, lcf.ScrubEmails, lcf.ScrubJWTs},
+		Mask:        "****",
+	}
+
+Errors (e.g. from logrus.WithError), fmt.Stringers, byte slices and numbers are scrubbed as text. Maps, structs and
+slices holding a matching key or a scrubbed secret at any depth are masked completely, or with CustomFormatter.Flatten
+enabled only their nested keys (e.g. "user.password") and values are masked.
+
+Per-Level Templates
+
+CustomFormatter.Templates overrides the template for some log levels, e.g. to show the caller of errors while debug
+lines stay compact. Each template is compiled once. Use ParseLevelTemplate to check it strictly on startup:
+
+	formatter := lcf.NewFormatter(lcf.Basic, nil)
+	formatter.Templates = map[logrus.Level]string{
+		logrus.ErrorLevel: "%[levelName]s:%[name]s:%[message]s (%[fileName]s:%[lineNo]d)%[fields]s\n",
+	}
+
+Level Colors
+
+The ColorTrace, ColorDebug, ColorInfo, ColorWarn, ColorError, ColorFatal and ColorPanic fields are Styles: one of the
+16 Ansi* colors, a color from the 256 color palette (Color256), or a 24-bit color (RGB), optionally with a background
+color and text attributes. ParseColor reads the same from text, e.g. from a config file:
+
+	formatter.ColorWarn = lcf.Color256(208).Bold()
+	formatter.ColorError, _ = lcf.ParseColor("bold #ffffff on red")
+
+Colors are downgraded to the closest color of CustomFormatter.Palette, which is detected from the COLORTERM and TERM
+environment variables.
+
+Colors are only written when the output is a terminal, unless the environment says otherwise. NO_COLOR disables
+colors, CLICOLOR_FORCE and FORCE_COLOR force them, and TERM=dumb, CLICOLOR=0 or FORCE_COLOR=0 disable them, in that
+order of precedence. NewFormatter only sets the DisableColors field from these variables and the output, so setting
+ForceColors or DisableColors in code afterwards overrides the environment.
+
+NewFormatter checks whether the standard logger's output is a terminal. For other loggers or hooks writing to files
+use NewFormatterWriter, or ForWriter to share one formatter between several outputs with colors decided for each:
+
+	logger.Formatter = formatter.ForWriter(logger.Out)
+	hook.SetFormatter(formatter.ForWriter(file))
+
+Themes
+
+A Theme sets the level colors together with the colors of field keys and values, %[ascTime]s, %[name]s and the caller
+attributes. The bundled themes are default, solarized-dark, solarized-light, monochrome and high-contrast:
+
+	theme, err := lcf.ThemeByName("solarized-dark")
+	if err != nil {
+		panic(err)
+	}
+	formatter.SetTheme(theme)
+
+New formatters start with the theme named by the LCF_THEME environment variable (e.g. LCF_THEME=solarized-light), so
+everyone can pick the theme that is readable on their terminal without changing code.
+
+Level Styles
+
+CustomFormatter.LevelStyles overrides the names and colors of levels, e.g. for custom numeric levels:
+
+	formatter.LevelStyles = lcf.LevelStyles{
+		logrus.Level(10): {Name: "NOTICE", ShortName: "NOTE", Code: "N", Color: lcf.AnsiBlue},
+	}
+
+Multiple Outputs
+
+To log to several outputs at once, each with its own colors and optionally its own template, add a SinkHook. Entries
+are rendered once per sink, e.g. compact and colorful on the console and detailed without colors in a file:
+
+	console := lcf.NewFormatter("%[shortLevelName]s %[message]s%[fields]s\n", nil)
+	detailed := lcf.NewFormatter(lcf.Detailed, nil)
+	logger.AddHook(lcf.NewSinkHook(lcf.NewSink(os.Stderr, console), lcf.NewSink(file, detailed)))
+	logger.Out = ioutil.Discard
+
+Stripping and HTML
+
+StripANSIWriter removes color codes from output of any formatter, e.g. colored output piped into CI logs or files:
+
+	logger.Out = lcf.StripANSIWriter(file)
+
+Colored output can be shown in browsers (e.g. in CI test reports) with ANSIToHTML or an HTMLWriter. Text is written in
+a <pre> block so padding stays aligned, and colored text in spans with CSS classes of its colors (see HTMLStyleSheet)
+and of the formatter colors it matches, e.g. "lcf-warn" or "lcf-timestamp":
+
+	page := lcf.ANSIToHTML(string(coloredLines), formatter)
+
+Formatter colors are only told apart by their styles. Text in a color shared by several of them (e.g. ColorFatal and
+ColorPanic) gets none of their classes, so give each formatter color a unique style (e.g. set ColorFieldKey, which
+uses the level color by default) to style every level and attribute separately.
+
+Python Templates
+
+Templates may also use Python's logging.Formatter syntax, so the same format string can be shared between Python and Go
+programs. Python attribute names are mapped to lcf attributes with the PythonAttributes map:
+
+	%(asctime)s,%(msecs)03d %(levelname)-8s %(name)s: %(message)s
+
+Both syntaxes can be mixed in one template. Python's relativeCreated is in milliseconds like lcf's relativeMsecs.
+
+Like Python's style argument NewFormatterStyle accepts str.format (BraceStyle) and string.Template (DollarStyle)
+templates. Attribute names may be lcf or Python names:
+
+	lcf.NewFormatterStyle("{levelName:<8} {name}: {message}\n", lcf.BraceStyle, nil)
+	lcf.NewFormatterStyle("$levelname ${name}: $message\n", lcf.DollarStyle, nil)
+
 Custom Handlers
 
 If what you're looking for is not available in the above built-in attributes or not exactly the functionality that you
 want you can add new or override existing attributes with custom handlers. Read the documentation for the CustomHandlers
 type below for more information.
+
+Template Errors
+
+NewFormatter ignores attributes it does not know about and leaves them in the template. When templates come from
+configuration files use NewFormatterE instead, which returns a *ParseError pointing to the unknown attribute,
+unsupported verb, unbalanced bracket, or stray % sign (use %% for a literal percent sign):
+
+	formatter, err := lcf.NewFormatterE("%[levelName]s %[mesage]s\n", nil)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	lcf: unknown attribute "mesage" at line 1 column 17 (offset 16)
+		%[levelName]s %[mesage]s
+		                ^
 */
 package lcf
@@ -0,0 +1,74 @@
+package lcf
+
+import "sort"
+
+// FieldOrder selects which fields %[fields]s shows and in which order. The zero value shows all fields sorted by key
+// (in random order if CustomFormatter.DisableSorting is true).
+//
+// logrus.Fields is a map so the order fields were added in is not known. List keys in First to keep them in a fixed
+// order.
+type FieldOrder struct {
+	// Keys shown before all other fields in this order (e.g. "request_id", "user").
+	First []string
+
+	// Orders the keys not in First. Sorted by key if nil.
+	Less func(key1, key2 string) bool
+
+	// Keys never shown.
+	Exclude []string
+
+	// If not empty only these keys and the keys in First are shown.
+	Include []string
+}
+
+// shows returns true if a field is shown.
+func (o *FieldOrder) shows(key string) bool {
+	if containsKey(o.Exclude, key) {
+		return false
+	}
+	return len(o.Include) == 0 || containsKey(o.Include, key) || containsKey(o.First, key)
+}
+
+// sort orders the keys of %[fields]s: keys in First, then the other keys ordered by Less, by key, or not at all if
+// disableSorting is true.
+func (o *FieldOrder) sort(keys []string, disableSorting bool) {
+	if len(o.First) == 0 && o.Less == nil {
+		if !disableSorting {
+			sort.Strings(keys)
+		}
+		return
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		rank1, rank2 := o.rank(keys[i]), o.rank(keys[j])
+		switch {
+		case rank1 != rank2:
+			return rank1 < rank2
+		case rank1 < len(o.First):
+			return false
+		case o.Less != nil:
+			return o.Less(keys[i], keys[j])
+		default:
+			return !disableSorting && keys[i] < keys[j]
+		}
+	})
+}
+
+// rank returns the index of a key in First or len(First) if it is not in it.
+func (o *FieldOrder) rank(key string) int {
+	for i, first := range o.First {
+		if first == key {
+			return i
+		}
+	}
+	return len(o.First)
+}
+
+// containsKey returns true if key is in keys.
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
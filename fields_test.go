@@ -0,0 +1,72 @@
+package lcf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldOrder(t *testing.T) {
+	byLength := func(key1, key2 string) bool { return len(key1) < len(key2) }
+	reverse := func(key1, key2 string) bool { return key1 > key2 }
+
+	testCases := []struct {
+		name     string
+		order    FieldOrder
+		expected string
+	}{
+		{"default", FieldOrder{}, " a=1 bb=2 ccc=3 request_id=r1 user=u1"},
+		{"first", FieldOrder{First: []string{"user", "request_id", "missing"}}, " user=u1 request_id=r1 a=1 bb=2 ccc=3"},
+		{"less", FieldOrder{Less: reverse}, " user=u1 request_id=r1 ccc=3 bb=2 a=1"},
+		{"first and less", FieldOrder{First: []string{"bb"}, Less: byLength}, " bb=2 a=1 ccc=3 user=u1 request_id=r1"},
+		{"exclude", FieldOrder{Exclude: []string{"bb", "user"}}, " a=1 ccc=3 request_id=r1"},
+		{"include", FieldOrder{Include: []string{"ccc", "a", "missing"}}, " a=1 ccc=3"},
+		{
+			"include with first",
+			FieldOrder{First: []string{"request_id"}, Include: []string{"ccc", "a"}, Exclude: []string{"a"}},
+			" request_id=r1 ccc=3",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+			formatter := NewFormatter("%[fields]s", nil)
+			formatter.DisableColors = true
+			formatter.FieldOrder = tc.order
+			entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+				"a": 1, "bb": 2, "ccc": 3, "request_id": "r1", "user": "u1",
+			})
+
+			actual, err := formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal(tc.expected, string(actual))
+
+			value, err := HandlerFields(entry, formatter)
+			assert.NoError(err)
+			assert.Equal(tc.expected, value)
+		})
+	}
+}
+
+func TestFieldOrder_DisableSorting(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%[.a]s%[fields]s", nil)
+	formatter.DisableColors = true
+	formatter.DisableSorting = true
+	formatter.FieldOrder.First = []string{"request_id", "a"}
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"a": 1, "bb": 2, "ccc": 3, "request_id": "r1", "user": "u1",
+	})
+
+	// Keys in First lead, the others come in map order. Fields with their own attribute are omitted.
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(string(actual), "1 request_id=r1 "), string(actual))
+	for _, pair := range []string{" bb=2", " ccc=3", " user=u1"} {
+		assert.Contains(string(actual), pair)
+	}
+	assert.Len(actual, len("1 request_id=r1 bb=2 ccc=3 user=u1"))
+}
@@ -0,0 +1,222 @@
+package lcf
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Filter prepares one filter of an attribute's pipeline (e.g. "trunc:20" in "%[name|trunc:20]s"). It is called once
+// when the template is parsed with the text after the filter name's colon (empty if there is none) and returns the
+// function applied to the attribute's value for every entry. Returning an error rejects the template.
+//
+// Values are passed to the returned function formatted like fmt's %v verb and may contain ANSI color sequences.
+type Filter func(arg string) (func(value string) string, error)
+
+// errUnknownFilter is returned by compileFilters for filter names that are neither built-in nor registered.
+var errUnknownFilter = errors.New("unknown filter")
+
+var (
+	filtersMutex  sync.RWMutex
+	customFilters = make(map[string]Filter)
+)
+
+// RegisterFilter makes a filter available in template pipelines of formatters parsed afterwards. Custom filters take
+// precedence over built-in filters with the same name.
+//
+// :param name: Name used in templates (e.g. "reverse" for "%[message|reverse]s").
+//
+// :param filter: Prepares the function applied to attribute values.
+func RegisterFilter(name string, filter Filter) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	customFilters[name] = filter
+}
+
+// lookupFilter returns the Filter of a filter name. Custom filters take precedence over built-in ones.
+func lookupFilter(name string) (Filter, bool) {
+	filtersMutex.RLock()
+	filter, ok := customFilters[name]
+	filtersMutex.RUnlock()
+	if ok {
+		return filter, true
+	}
+	switch name {
+	case "basename":
+		return filterBasename, true
+	case "center":
+		return filterCenter, true
+	case "default":
+		return filterDefault, true
+	case "lower":
+		return filterLower, true
+	case "trunc":
+		return filterTrunc, true
+	case "upper":
+		return filterUpper, true
+	}
+	return nil, false
+}
+
+// filterBasename keeps the last element of a path (e.g. "main.go" from "/src/app/main.go").
+func filterBasename(string) (func(string) string, error) {
+	return func(value string) string {
+		if value == "" {
+			return ""
+		}
+		return filepath.Base(value)
+	}, nil
+}
+
+// filterCenter pads the value with spaces on both sides to the given width in terminal columns. Extra padding goes to
+// the right.
+func filterCenter(arg string) (func(string) string, error) {
+	width, err := filterInt(arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(value string) string {
+		padding := width - visibleWidth([]byte(value))
+		if padding <= 0 {
+			return value
+		}
+		return strings.Repeat(" ", padding/2) + value + strings.Repeat(" ", padding-padding/2)
+	}, nil
+}
+
+// filterDefault replaces empty values with the argument (e.g. "main" in "%[name|default:main]s").
+func filterDefault(arg string) (func(string) string, error) {
+	return func(value string) string {
+		if visibleRuneCount([]byte(value)) == 0 {
+			return arg
+		}
+		return value
+	}, nil
+}
+
+// filterLower lower cases the value's text without touching ANSI color sequences.
+func filterLower(string) (func(string) string, error) {
+	return func(value string) string { return mapVisible(value, strings.ToLower) }, nil
+}
+
+// filterTrunc cuts the value to the given number of visible characters. ANSI color sequences are kept so colors
+// are still reset. An optional marker after a second colon replaces the end of cut values (e.g. "trunc:20:…").
+func filterTrunc(arg string) (func(string) string, error) {
+	var ellipsis string
+	if colon := strings.IndexByte(arg, ':'); colon >= 0 {
+		arg, ellipsis = arg[:colon], arg[colon+1:]
+	}
+	width, err := filterInt(arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(value string) string { return truncateVisible(value, width, ellipsis) }, nil
+}
+
+// filterUpper upper cases the value's text without touching ANSI color sequences.
+func filterUpper(string) (func(string) string, error) {
+	return func(value string) string { return mapVisible(value, strings.ToUpper) }, nil
+}
+
+// filterInt parses the non-negative integer argument of a filter.
+func filterInt(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		return 0, errors.New("expected a non-negative integer argument")
+	}
+	return n, nil
+}
+
+// mapVisible applies fn to the text between ANSI color sequences.
+func mapVisible(value string, fn func(string) string) string {
+	if !hasEscape(value) {
+		return fn(value)
+	}
+	b := []byte(value)
+	var buffer bytes.Buffer
+	start := 0
+	for i := 0; i < len(b); {
+		if n := ansiSequenceLen(b[i:]); n > 0 {
+			buffer.WriteString(fn(value[start:i]))
+			buffer.WriteString(value[i : i+n])
+			i += n
+			start = i
+			continue
+		}
+		i++
+	}
+	buffer.WriteString(fn(value[start:]))
+	return buffer.String()
+}
+
+// truncateVisible keeps the first n visible runes of value and all of its ANSI color sequences. If the value is cut the
+// ellipsis replaces its last visible runes.
+func truncateVisible(value string, n int, ellipsis string) string {
+	var buffer bytes.Buffer
+	if writeTruncated(&buffer, []byte(value), n, ellipsis) {
+		return buffer.String()
+	}
+	return value
+}
+
+// writeTruncated writes src to the buffer keeping only its first n visible runes and all of its ANSI color sequences,
+// so colors opened before the cut are still reset. If src is cut the ellipsis takes the place of its last visible
+// runes (it is left out if it does not fit in n). Returns false if src was not cut.
+func writeTruncated(buffer *bytes.Buffer, src []byte, n int, ellipsis string) bool {
+	if visibleRuneCount(src) <= n {
+		buffer.Write(src)
+		return false
+	}
+	keep := n - utf8.RuneCountInString(ellipsis)
+	if keep < 0 {
+		keep, ellipsis = n, ""
+	}
+	cut := false
+	for i := 0; i < len(src); {
+		if size := ansiSequenceLen(src[i:]); size > 0 {
+			buffer.Write(src[i : i+size])
+			i += size
+			continue
+		}
+		if cut && bytes.IndexByte(src[i:], '\033') < 0 {
+			break
+		}
+		_, size := utf8.DecodeRune(src[i:])
+		switch {
+		case keep > 0:
+			buffer.Write(src[i : i+size])
+			keep--
+		case !cut:
+			buffer.WriteString(ellipsis)
+			cut = true
+		}
+		i += size
+	}
+	return true
+}
+
+// compileFilters prepares an attribute's filter pipeline. On failure the index of the bad option is returned with the
+// error.
+func compileFilters(options []string) ([]func(string) string, int, error) {
+	filters := make([]func(string) string, 0, len(options))
+	for i, option := range options {
+		name, arg := option, ""
+		if colon := strings.IndexByte(option, ':'); colon >= 0 {
+			name, arg = option[:colon], option[colon+1:]
+		}
+		filter, ok := lookupFilter(name)
+		if !ok {
+			return nil, i, errUnknownFilter
+		}
+		fn, err := filter(arg)
+		if err != nil {
+			return nil, i, err
+		}
+		filters = append(filters, fn)
+	}
+	return filters, 0, nil
+}
@@ -0,0 +1,110 @@
+package lcf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilters(t *testing.T) {
+	testCases := []struct {
+		template    string
+		expected    string
+		forceColors bool
+	}{
+		{"%[message|upper]s", "SAMPLE WARN 2.", false},
+		{"%[message|lower]s", "sample warn 2.", false},
+		{"%-10[message|trunc:6]s|", "Sample    |", false},
+		{"%-10[message|trunc:6:…]s|", "Sampl…    |", false},
+		{"%[message|trunc:0]s|", "|", false},
+		{"%[.missing|default:main]s", "main", false},
+		{"%[name|default:main]s", "LogMsgs", false},
+		{"%-20[.missing|trunc:2|default:main]s|", "main                |", false},
+		{"%[.path|basename]s", "main.go", false},
+		{"[%[name|center:12]s]", "[  LogMsgs   ]", false},
+		{"[%[name|center:3]s]", "[LogMsgs]", false},
+		{"%[levelName|lower]s", "\033[33mwarning\033[0m", true},
+		{"%-6[levelName|lower|trunc:4]s|", "\033[33mwarn\033[0m  |", true},
+		{"[%[levelName|center:9]s]", "[ \033[33mWARNING\033[0m ]", true},
+		{"%[.c|default:0]q %05[process|trunc:0|default:7]s", `"10" 00007`, false},
+		{"%5[.c|upper]d|%-4[.missing|default:0]d|%[.c|upper]x", "   10|0   |3130", false},
+		{"%{<%[.missing|default:x]s>%}%{(%[.missing|upper]s)%}", "<x>", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			formatter, err := NewFormatterE(tc.template, nil)
+			assert.NoError(err)
+			formatter.ForceColors = tc.forceColors
+			formatter.DisableColors = !tc.forceColors
+			entry := newBenchmarkEntry(formatter)
+			entry.Data["path"] = "/src/app/main.go"
+
+			actual, err := formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal(tc.expected, string(actual))
+		})
+	}
+}
+
+func TestFiltersSprintf(t *testing.T) {
+	assert := require.New(t)
+	formatter, err := NewFormatterE("%-6[message|upper|trunc:3]s|%[process|trunc:4]s|", nil)
+	assert.NoError(err)
+	assert.Equal("ABC   |1234|", formatter.Sprintf("abcdef", 123456))
+}
+
+func TestRegisterFilter(t *testing.T) {
+	assert := require.New(t)
+	RegisterFilter("repeat", func(arg string) (func(string) string, error) {
+		count, err := filterInt(arg)
+		if err != nil {
+			return nil, errors.New("bad count")
+		}
+		return func(value string) string { return strings.Repeat(value, count) }, nil
+	})
+
+	formatter, err := NewFormatterE("%[message|trunc:2|repeat:3]s", nil)
+	assert.NoError(err)
+	formatter.DisableColors = true
+	actual, err := formatter.Format(newBenchmarkEntry(formatter))
+	assert.NoError(err)
+	assert.Equal("SaSaSa", string(actual))
+
+	_, err = NewFormatterE("%[message|repeat:x]s", nil)
+	assert.EqualError(err, "lcf: invalid filter \"repeat:x\" (bad count) for \"message\" at line 1 column 11 (offset 10)\n"+
+		"\t%[message|repeat:x]s\n"+
+		"\t          ^")
+}
+
+func TestTruncateVisible(t *testing.T) {
+	testCases := []struct {
+		value    string
+		n        int
+		ellipsis string
+		expected string
+	}{
+		{"abc", 5, "", "abc"},
+		{"abc", 2, "", "ab"},
+		{"日本語", 2, "", "日本"},
+		{"\033[31mabc\033[0m", 1, "", "\033[31ma\033[0m"},
+		{"\033[31mab\033[0mcd\033[32mef\033[0m", 3, "", "\033[31mab\033[0mc\033[32m\033[0m"},
+		{"\033[31mabc\033[0m def", 0, "", "\033[31m\033[0m"},
+		{"abc", 3, "…", "abc"},
+		{"abcd", 3, "…", "ab…"},
+		{"abcd", 3, "...", "..."},
+		{"abcd", 2, "...", "ab"},
+		{"\033[31mabcd\033[0m", 3, "…", "\033[31mab…\033[0m"},
+		{"\033[31mab\033[0mcd", 2, "~", "\033[31ma~\033[0m"},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q %d %q", tc.value, tc.n, tc.ellipsis), func(t *testing.T) {
+			assert := require.New(t)
+			assert.Equal(tc.expected, truncateVisible(tc.value, tc.n, tc.ellipsis))
+		})
+	}
+}
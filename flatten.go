@@ -0,0 +1,339 @@
+package lcf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Limits used when FlattenOptions.MaxDepth or MaxElements is 0.
+const (
+	defaultFlattenDepth    = 5
+	defaultFlattenElements = 100
+)
+
+// FlattenOptions configures how %[fields]s expands nested maps, structs, slices and arrays into dotted keys (e.g.
+// "http.method=GET user.id=42 tags.0=a"). Map keys are sorted, struct fields keep their order and are named after their
+// json tags, and pointers are followed. Values with a ValueRenderer (looked up by the dotted key first), errors and
+// fmt.Stringers are not expanded.
+type FlattenOptions struct {
+	// Expand nested values. Disabled by default.
+	Enabled bool
+
+	// Levels of nesting expanded. Deeper values are written as a whole, or as "<cycle>" if they contain themselves. 5
+	// if 0.
+	MaxDepth int
+
+	// Elements of each map, struct, slice or array written. The number of left out elements is written as
+	// "key._truncated=N". 100 if 0.
+	MaxElements int
+}
+
+// flattenCycle is written instead of values that contain themselves.
+const flattenCycle = "<cycle>"
+
+// flattener writes the dotted keys of one field of %[fields]s.
+type flattener struct {
+	s           *renderState
+	formatter   *CustomFormatter
+	level       logrus.Level
+	maxDepth    int
+	maxElements int
+	path        []flattenRef // Maps, slices and pointers being expanded, for cycle protection.
+}
+
+// flattenRef identifies a map, slice or pointer being expanded. The type is kept because a struct and its first field
+// share an address.
+type flattenRef struct {
+	pointer uintptr
+	typ     reflect.Type
+}
+
+// appendFlattened writes a field of %[fields]s with nested values expanded into dotted keys.
+func (f *CustomFormatter) appendFlattened(s *renderState, level logrus.Level, key string, value interface{}) {
+	fl := flattener{s: s, formatter: f, level: level, maxDepth: f.Flatten.MaxDepth, maxElements: f.Flatten.MaxElements}
+	if fl.maxDepth <= 0 {
+		fl.maxDepth = defaultFlattenDepth
+	}
+	if fl.maxElements <= 0 {
+		fl.maxElements = defaultFlattenElements
+	}
+	fl.walk(key, reflect.ValueOf(value), 0)
+}
+
+// walk writes a value at depth levels of nesting, expanding it if it is a map, struct, slice, array or pointer to one.
+func (fl *flattener) walk(key string, v reflect.Value, depth int) {
+	if !v.IsValid() {
+		fl.leaf(key, nil)
+		return
+	}
+	value := v.Interface()
+
+	// Top-level fields were redacted before formatting, nested fields are redacted here. Values holding secrets are
+	// expanded with the secrets masked and written as the mask when not expanded.
+	masked, redacted := value.(redactedValue)
+	if !redacted && depth > 0 && fl.formatter.Redact.enabled() {
+		if m, ok := fl.formatter.Redact.field(key, value); ok {
+			if masked, redacted = m.(redactedValue); !redacted {
+				fl.leaf(key, m)
+				return
+			}
+		}
+	}
+	if redacted {
+		if depth >= fl.maxDepth || fl.formatter.renderer(key, masked.value) != nil {
+			fl.leaf(key, masked)
+			return
+		}
+		v, value = reflect.ValueOf(masked.value), masked.value
+	}
+
+	if fl.formatter.renderer(key, value) != nil {
+		fl.leaf(key, value)
+		return
+	}
+	if depth >= fl.maxDepth {
+		// fmt prints deeper values and overflows the stack on cycles.
+		if fl.cyclic(v, true) {
+			fl.leaf(key, flattenCycle)
+		} else {
+			fl.leaf(key, value)
+		}
+		return
+	}
+	switch value.(type) {
+	case error, fmt.Stringer:
+		fl.leaf(key, value)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			fl.leaf(key, value)
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			if !fl.enter(v) {
+				fl.leaf(key, flattenCycle)
+				return
+			}
+			defer fl.leave()
+		}
+		fl.walk(key, v.Elem(), depth)
+	case reflect.Map:
+		if v.Len() == 0 {
+			fl.leaf(key, value)
+			return
+		}
+		if !fl.enter(v) {
+			fl.leaf(key, flattenCycle)
+			return
+		}
+		defer fl.leave()
+		// Keys are kept with their values because different keys (e.g. 1 and "1") may print the same.
+		entries := make([]structField, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			entries = append(entries, structField{fmt.Sprint(k.Interface()), v.MapIndex(k)})
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+		for i, entry := range entries {
+			if fl.truncate(key, i, len(entries)) {
+				break
+			}
+			fl.walk(key+"."+entry.name, entry.value, depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			fl.leaf(key, value)
+			return
+		}
+		if v.Kind() == reflect.Slice {
+			if !fl.enter(v) {
+				fl.leaf(key, flattenCycle)
+				return
+			}
+			defer fl.leave()
+		}
+		for i := 0; i < v.Len(); i++ {
+			if fl.truncate(key, i, v.Len()) {
+				break
+			}
+			fl.walk(key+"."+strconv.Itoa(i), v.Index(i), depth+1)
+		}
+	case reflect.Struct:
+		fields := structFields(v, nil)
+		if len(fields) == 0 {
+			fl.leaf(key, value)
+			return
+		}
+		for i, field := range fields {
+			if fl.truncate(key, i, len(fields)) {
+				break
+			}
+			fl.walk(key+"."+field.name, field.value, depth+1)
+		}
+	default:
+		fl.leaf(key, value)
+	}
+}
+
+// leaf writes one key and value.
+func (fl *flattener) leaf(key string, value interface{}) {
+	fl.formatter.appendFieldPair(fl.s, fl.level, key, value)
+}
+
+// truncate writes the number of left out elements and returns true if the i-th of n elements is past MaxElements.
+func (fl *flattener) truncate(key string, i, n int) bool {
+	if i < fl.maxElements {
+		return false
+	}
+	fl.leaf(key+"._truncated", n-i)
+	return true
+}
+
+// cyclic returns true if fmt printing a value would recurse into a map, slice or pointer being expanded or into a
+// value containing itself. fmt follows maps, slices, arrays, structs and interfaces, but pointers only at the top.
+func (fl *flattener) cyclic(v reflect.Value, top bool) bool {
+	if !v.IsValid() {
+		return false
+	}
+	if v.CanInterface() {
+		switch v.Interface().(type) {
+		case error, fmt.Stringer, fmt.Formatter:
+			return false // Printed by their methods.
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !top || v.IsNil() {
+			return false
+		}
+		if !fl.enter(v) {
+			return true
+		}
+		defer fl.leave()
+		return fl.cyclic(v.Elem(), false)
+	case reflect.Interface:
+		return fl.cyclic(v.Elem(), top)
+	case reflect.Map:
+		if v.Len() == 0 {
+			return false
+		}
+		if !fl.enter(v) {
+			return true
+		}
+		defer fl.leave()
+		for _, k := range v.MapKeys() {
+			if fl.cyclic(k, false) || fl.cyclic(v.MapIndex(k), false) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return false
+		}
+		if v.Kind() == reflect.Slice {
+			if !fl.enter(v) {
+				return true
+			}
+			defer fl.leave()
+		}
+		for i := 0; i < v.Len(); i++ {
+			if fl.cyclic(v.Index(i), false) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if fl.cyclic(v.Field(i), false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enter adds a map, slice or pointer to the path of values being expanded. Returns false if it is already on it.
+func (fl *flattener) enter(v reflect.Value) bool {
+	ref := flattenRef{v.Pointer(), v.Type()}
+	for _, r := range fl.path {
+		if r == ref {
+			return false
+		}
+	}
+	fl.path = append(fl.path, ref)
+	return true
+}
+
+// leave removes the last value added by enter.
+func (fl *flattener) leave() {
+	fl.path = fl.path[:len(fl.path)-1]
+}
+
+// structField is an exported struct field as encoding/json names it, or a map entry with its key printed.
+type structField struct {
+	name  string
+	value reflect.Value
+}
+
+// structFields appends the exported fields of a struct to fields the way encoding/json encodes them: named after
+// their json tags, skipping fields tagged "-" and empty "omitempty" fields, and promoting fields of embedded structs.
+func structFields(v reflect.Value, fields []structField) []structField {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.IndexByte(tag, ','); comma >= 0 {
+			name, options = tag[:comma], tag[comma:]
+		}
+		fv := v.Field(i)
+
+		// Promote fields of embedded structs without a json name. Embedded pointers are expanded like other fields so
+		// cycles are caught.
+		if sf.Anonymous && name == "" && fv.Kind() == reflect.Struct {
+			fields = structFields(fv, fields)
+			continue
+		}
+
+		if sf.PkgPath != "" || !fv.CanInterface() {
+			continue // Unexported.
+		}
+		if strings.Contains(options, ",omitempty") && isEmptyJSON(fv) {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, structField{name, fv})
+	}
+	return fields
+}
+
+// isEmptyJSON returns true if encoding/json considers a value empty for "omitempty".
+func isEmptyJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package lcf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type flattenAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type flattenMeta struct {
+	Source string `json:"source"`
+}
+
+type flattenUser struct {
+	flattenMeta
+	ID       int             `json:"id"`
+	Name     string          `json:"name"`
+	Password string          `json:"-"`
+	Address  *flattenAddress `json:"address"`
+	Tags     []string        `json:"tags,omitempty"`
+	Created  time.Time       `json:"created"`
+	secret   string
+}
+
+type flattenNode struct {
+	Name string
+	Next *flattenNode
+}
+
+func TestFlatten(t *testing.T) {
+	user := flattenUser{
+		flattenMeta: flattenMeta{"db"},
+		ID:          42,
+		Name:        "Alice",
+		Password:    "hunter2",
+		Address:     &flattenAddress{City: "New York"},
+		Created:     time.Date(2016, 10, 30, 19, 12, 17, 149000000, time.UTC),
+		secret:      "s",
+	}
+	loop := &flattenNode{Name: "a"}
+	loop.Next = &flattenNode{Name: "b", Next: loop}
+	self := map[string]interface{}{"x": 1}
+	self["self"] = self
+	list := []interface{}{"a", nil}
+	list[1] = list
+	nested := map[string]interface{}{"a": self, "b": []int{1}}
+
+	testCases := []struct {
+		name     string
+		options  FlattenOptions
+		value    interface{}
+		expected string
+	}{
+		{"scalar", FlattenOptions{}, "GET", " v=GET"},
+		{
+			"map",
+			FlattenOptions{},
+			map[string]interface{}{"method": "GET", "status": 200, "headers": map[string][]string{"Accept": {"a", "b"}}},
+			" v.headers.Accept.0=a v.headers.Accept.1=b v.method=GET v.status=200",
+		},
+		{
+			"struct",
+			FlattenOptions{},
+			user,
+			` v.source=db v.id=42 v.name=Alice v.address.city="New York" v.created="2016-10-30 19:12:17.149"`,
+		},
+		{"pointer", FlattenOptions{}, &flattenAddress{"Paris", "75001"}, " v.city=Paris v.zip=75001"},
+		{"array", FlattenOptions{}, [2]int{1, 2}, " v.0=1 v.1=2"},
+		{"empty", FlattenOptions{}, map[string]int{}, " v=map[]"},
+		{"nil", FlattenOptions{}, (*flattenAddress)(nil), " v=<nil>"},
+		{"no fields", FlattenOptions{}, struct{ a int }{1}, " v={1}"},
+		{"leaves", FlattenOptions{}, []interface{}{errors.New("x y"), time.Second, []byte{1}}, ` v.0="x y" v.1=1s v.2=01`},
+		{"depth", FlattenOptions{MaxDepth: 1}, map[string]interface{}{"a": map[string]int{"b": 1}}, " v.a=map[b:1]"},
+		{"elements", FlattenOptions{MaxElements: 2}, []int{1, 2, 3, 4, 5}, " v.0=1 v.1=2 v._truncated=3"},
+		{"pointer cycle", FlattenOptions{}, loop, " v.Name=a v.Next.Name=b v.Next.Next=<cycle>"},
+		{"map cycle", FlattenOptions{}, self, " v.self=<cycle> v.x=1"},
+		{"slice cycle", FlattenOptions{}, list, " v.0=a v.1=<cycle>"},
+		{"cycle at depth", FlattenOptions{MaxDepth: 1}, self, " v.self=<cycle> v.x=1"},
+		{"cycle below depth", FlattenOptions{MaxDepth: 1}, nested, " v.a=<cycle> v.b=[1]"},
+		{"deep cycle", FlattenOptions{MaxDepth: 100, MaxElements: 1}, loop, " v.Name=a v._truncated=1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+			formatter := NewFormatter("%[fields]s", nil)
+			formatter.DisableColors = true
+			formatter.Flatten = tc.options
+			formatter.Flatten.Enabled = true
+			entry := logrus.NewEntry(logrus.New()).WithField("v", tc.value)
+
+			actual, err := formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal(tc.expected, string(actual))
+		})
+	}
+}
+
+func TestFlatten_SameKeys(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%[fields]s", nil)
+	formatter.DisableColors = true
+	formatter.Flatten.Enabled = true
+	entry := logrus.NewEntry(logrus.New()).WithField("m", map[interface{}]int{1: 1, "1": 2})
+
+	// Keys printing the same are both written in random order.
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Contains([]string{" m.1=1 m.1=2", " m.1=2 m.1=1"}, string(actual))
+}
+
+func TestFlatten_Renderers(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%[.http]s|%[fields]s", nil)
+	formatter.DisableColors = true
+	formatter.Flatten.Enabled = true
+	formatter.FieldRenderers = map[string]ValueRenderer{
+		"user.address": RenderJSON,
+		"user.name": func(b []byte, _ interface{}, _ *CustomFormatter) []byte {
+			return append(b, "***"...)
+		},
+	}
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"http": map[string]string{"method": "GET"},
+		"user": flattenUser{ID: 1, Name: "Bob", Address: &flattenAddress{City: "Rome"}},
+	})
+
+	// Field-reference attributes are not flattened.
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal(`map[method:GET]| user.source="" user.id=1 user.name=*** user.address="{\"city\":\"Rome\"}"`+
+		` user.created="0001-01-01 00:00:00.000"`, string(actual))
+}
+
+func TestFlatten_Colors(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%[fields]s", nil)
+	formatter.ForceColors = true
+	formatter.ColorFieldKey = AnsiBlue
+	formatter.Flatten.Enabled = true
+	entry := logrus.NewEntry(logrus.New()).WithField("http", map[string]interface{}{"method": "GET", "path": "/"})
+
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal(" \033[34mhttp.method\033[0m=GET \033[34mhttp.path\033[0m=/", string(actual))
+}
@@ -1,8 +1,8 @@
 package lcf
 
 import (
-	"bytes"
-	"runtime"
+	"io"
+	"reflect"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -27,16 +27,18 @@ type CustomFormatter struct {
 	// Post-processed formatting template (e.g. "%s:%s:%s\n").
 	Template string
 
-	// Handler functions whose indexes match up with Template Sprintf explicit argument indexes.
+	// Handler functions whose indexes match up with the attributes in the parsed template.
 	Handlers []Handler
 
 	// Attribute names (e.g. "levelName") used in pre-processed Template.
 	Attributes Attributes
 
-	// Set to true to bypass checking for a TTY before outputting colors.
+	// Set to true to bypass checking for a TTY before outputting colors. Takes precedence over DisableColors. Never set
+	// by NewFormatter so it is left to code.
 	ForceColors bool
 
-	// Force disabling colors and bypass checking for a TTY.
+	// Force disabling colors and bypass checking for a TTY. Set by NewFormatter if NO_COLOR is set, TERM is "dumb",
+	// CLICOLOR or FORCE_COLOR is "0", or the output is not a terminal, unless CLICOLOR_FORCE or FORCE_COLOR is set.
 	DisableColors bool
 
 	// Timestamp format %[ascTime]s will use for display when a full timestamp is printed.
@@ -46,33 +48,137 @@ type CustomFormatter struct {
 	// that log extremely frequently this may not be desired.
 	DisableSorting bool
 
-	// Different colors for different log levels.
-	ColorDebug int
-	ColorInfo  int
-	ColorWarn  int
-	ColorError int
-	ColorFatal int
-	ColorPanic int
+	// Fields shown by %[fields]s and their order (e.g. "request_id" first). All fields sorted by key by default.
+	FieldOrder FieldOrder
 
-	handleColors [][3]int
-	startTime    time.Time
+	// How %[fields]s quotes keys and values. Logfmt by default.
+	FieldQuoting FieldQuoting
+
+	// Expand nested maps, structs and slices in %[fields]s into dotted keys (e.g. "http.method=GET"). Disabled by
+	// default.
+	Flatten FlattenOptions
+
+	// Secrets masked in field values and messages before entries are formatted. Disabled by default.
+	Redact Redaction
+
+	// Renderers of field values by field key and by Go type used by %[fields]s and field-reference attributes. See
+	// ValueRenderer.
+	FieldRenderers map[string]ValueRenderer
+	TypeRenderers  map[reflect.Type]ValueRenderer
+
+	// Marker (e.g. "…") replacing the end of values cut by a precision such as %.10[name]s. Empty by default.
+	Ellipsis string
+
+	// Different colors for different log levels (e.g. AnsiRed, Color256(208).Bold() or RGB(255, 135, 0)).
+	ColorTrace Style
+	ColorDebug Style
+	ColorInfo  Style
+	ColorWarn  Style
+	ColorError Style
+	ColorFatal Style
+	ColorPanic Style
+
+	// Colors of field keys (AnsiReset uses the level color) and values in %[fields]s and field-reference attributes,
+	// %[ascTime]s, %[name]s, and the caller attributes %[fileName]s, %[funcName]s, %[module]s and %[pathName]s.
+	// Uncolored by default.
+	ColorFieldKey   Style
+	ColorFieldValue Style
+	ColorTimestamp  Style
+	ColorName       Style
+	ColorCaller     Style
+
+	// Names and colors of log levels overriding the defaults, e.g. for custom numeric levels.
+	LevelStyles LevelStyles
+
+	// Colors supported by the terminal. 256 and 24-bit colors are downgraded to the closest supported color. Detected
+	// from the COLORTERM and TERM environment variables by default.
+	Palette Palette
+
+	// Per-level template overrides (e.g. to add caller information to errors). Levels without one use Template. They
+	// are written in the same style as Template and compiled on first use, or strictly by ParseLevelTemplate().
+	Templates map[logrus.Level]string
+
+	compiled  *compiledTemplate
+	custom    CustomHandlers
+	style     TemplateStyle
+	startTime time.Time
 }
 
 // Format is called by logrus and returns the formatted string.
 func (f *CustomFormatter) Format(entry *logrus.Entry) ([]byte, error) {
-	// Call handlers.
-	values := make([]interface{}, len(f.Handlers))
-	for i, handler := range f.Handlers {
-		value, err := handler(entry, f)
-		if err != nil {
-			return nil, err
+	if f.Redact.enabled() {
+		entry = f.Redact.apply(entry)
+	}
+	c := f.compiled
+	if template, ok := f.Templates[entry.Level]; ok {
+		c = f.levelTemplate(entry.Level, template)
+	}
+	if c == nil {
+		c = &compiledTemplate{}
+	}
+	s := getRenderState()
+	defer putRenderState(s)
+	if err := f.render(s, entry, c); err != nil {
+		return nil, err
+	}
+	formatted := make([]byte, s.buffer.Len())
+	copy(formatted, s.buffer.Bytes())
+	return formatted, nil
+}
+
+// levelTemplate returns the compiled template of a level, compiling it leniently if it changed since it was last used.
+// Level templates are cached with the main template so they are compiled again with its custom handlers and style.
+func (f *CustomFormatter) levelTemplate(level logrus.Level, template string) *compiledTemplate {
+	if f.compiled != nil {
+		if value, ok := f.compiled.levels.Load(level); ok && value.(*compiledTemplate).source == template {
+			return value.(*compiledTemplate)
 		}
-		values[i] = value
 	}
+	nodes, _ := parseStyle(template, f.style, false)
+	c, _ := compileTemplate(template, nodes, f.custom, false)
+	if f.compiled != nil {
+		f.compiled.levels.Store(level, c)
+	}
+	return c
+}
 
-	// Parse template and return.
-	parsed := f.Sprintf(values...)
-	return bytes.NewBufferString(parsed).Bytes(), nil
+// ParseLevelTemplate strictly parses a template string used instead of Template for entries of one log level. It is
+// written in the same style as Template and uses the same custom handlers. The formatter is not modified if an error
+// is returned.
+//
+// :param level: Log level the template is used for.
+//
+// :param template: Pre-processed formatting template (e.g. "%[message]s %[fileName]s:%[lineNo]d\n").
+func (f *CustomFormatter) ParseLevelTemplate(level logrus.Level, template string) error {
+	nodes, err := parseStyle(template, f.style, true)
+	if err != nil {
+		return err
+	}
+	c, err := compileTemplate(template, nodes, f.custom, true)
+	if err != nil {
+		return err
+	}
+	if f.Templates == nil {
+		f.Templates = make(map[logrus.Level]string)
+	}
+	f.Templates[level] = template
+	if f.compiled != nil {
+		f.compiled.levels.Store(level, c)
+	}
+	return nil
+}
+
+// newFormatter returns a CustomFormatter with default settings, the colors of the LCF_THEME theme, and colors disabled
+// if not supported by w.
+func newFormatter(w io.Writer) *CustomFormatter {
+	formatter := CustomFormatter{
+		TimestampFormat: DefaultTimestampFormat,
+		Palette:         detectPalette(),
+		startTime:       time.Now(),
+	}
+	formatter.SetTheme(envTheme())
+	formatter.DisableColors = !colorsFor(w)
+	return &formatter
 }
 
 // NewFormatter creates a new CustomFormatter, sets the Template string, and returns its pointer.
@@ -83,24 +189,81 @@ func (f *CustomFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 // :param custom: User-defined formatters evaluated before built-in formatters. Keys are attributes to look for in the
 // 	formatting string (e.g. "%[myFormatter]s") and values are formatting functions.
 func NewFormatter(template string, custom CustomHandlers) *CustomFormatter {
-	formatter := CustomFormatter{
-		ColorDebug:      AnsiCyan,
-		ColorInfo:       AnsiGreen,
-		ColorWarn:       AnsiYellow,
-		ColorError:      AnsiRed,
-		ColorFatal:      AnsiMagenta,
-		ColorPanic:      AnsiMagenta,
-		TimestampFormat: DefaultTimestampFormat,
-		startTime:       time.Now(),
-	}
+	formatter := newFormatter(logrus.StandardLogger().Out)
+	formatter.ParseTemplate(template, custom)
+	return formatter
+}
 
-	// Parse the template string.
+// NewFormatterWriter is like NewFormatter but decides whether to write colors for w instead of the standard logger's
+// output. Use it for formatters of other loggers (e.g. NewFormatterWriter(logger.Out, ...)) or hooks writing to files.
+//
+// :param w: Writer the formatted entries are written to.
+//
+// :param template: Pre-processed formatting template (e.g. "%[message]s\n").
+//
+// :param custom: User-defined formatters evaluated before built-in formatters.
+func NewFormatterWriter(w io.Writer, template string, custom CustomHandlers) *CustomFormatter {
+	formatter := newFormatter(w)
 	formatter.ParseTemplate(template, custom)
+	return formatter
+}
+
+// ForWriter returns a logrus.Formatter for one more output of the formatter. It formats entries like the formatter
+// does, with its current template and settings, but writes colors only if the environment and w allow them (see
+// NewFormatter) instead of following ForceColors and DisableColors. This way one formatter can serve a terminal and a
+// log file written by a hook:
+//
+//	logger.Formatter = formatter.ForWriter(logger.Out)
+//	hook.SetFormatter(formatter.ForWriter(file))
+//
+// :param w: Writer the formatted entries are written to.
+func (f *CustomFormatter) ForWriter(w io.Writer) logrus.Formatter {
+	return &writerFormatter{formatter: f, colors: colorsFor(w)}
+}
 
-	// Disable colors if not supported.
-	if !logrus.IsTerminal(logrus.StandardLogger().Out) || (runtime.GOOS == "windows" && !WindowsNativeANSI()) {
-		formatter.DisableColors = true
+// writerFormatter is a CustomFormatter bound to an output by ForWriter.
+type writerFormatter struct {
+	formatter *CustomFormatter
+	colors    bool // Write colors to the output.
+}
+
+// Format is called by logrus and returns the formatted string.
+func (w *writerFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if w.formatter.colorsEnabled() == w.colors {
+		return w.formatter.Format(entry)
 	}
+	formatter := *w.formatter
+	formatter.ForceColors, formatter.DisableColors = w.colors, !w.colors
+	return formatter.Format(entry)
+}
 
-	return &formatter
+// NewFormatterE is like NewFormatter but strictly parses the template string. Problems such as unknown attributes
+// (e.g. a typo like "%[mesage]s") are returned as a *ParseError instead of ending up in log lines. Useful for failing
+// fast on startup when templates come from configuration files.
+//
+// :param template: Pre-processed formatting template (e.g. "%[message]s\n").
+//
+// :param custom: User-defined formatters evaluated before built-in formatters.
+func NewFormatterE(template string, custom CustomHandlers) (*CustomFormatter, error) {
+	formatter := newFormatter(logrus.StandardLogger().Out)
+	if err := formatter.ParseTemplateE(template, custom); err != nil {
+		return nil, err
+	}
+	return formatter, nil
+}
+
+// NewFormatterStyle is like NewFormatterE but for template strings written in the given style (e.g. BraceStyle for
+// "{levelName:<8} {message}\n").
+//
+// :param template: Pre-processed formatting template.
+//
+// :param style: Syntax of the template string.
+//
+// :param custom: User-defined formatters evaluated before built-in formatters.
+func NewFormatterStyle(template string, style TemplateStyle, custom CustomHandlers) (*CustomFormatter, error) {
+	formatter := newFormatter(logrus.StandardLogger().Out)
+	if err := formatter.ParseTemplateStyle(template, style, custom); err != nil {
+		return nil, err
+	}
+	return formatter, nil
 }
@@ -1,13 +1,16 @@
 package lcf
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rifflock/lfshook"
 	"github.com/sirupsen/logrus"
@@ -230,3 +233,248 @@ func TestNewFormatterCustom(t *testing.T) {
 		})
 	}
 }
+
+func TestNewFormatterPython(t *testing.T) {
+	assert := require.New(t)
+
+	formatter, err := NewFormatterE("%(asctime)s,%(msecs)03d %(levelname)-8s %(name)s: %(message)s [%(thread)d]\n", nil)
+	assert.NoError(err)
+	formatter.DisableColors = true
+	formatter.TimestampFormat = "2006-01-02 15:04:05"
+	entry := newBenchmarkEntry(formatter)
+	entry.Time = time.Date(2016, 10, 30, 19, 12, 17, 9000000, time.UTC)
+
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	expected := fmt.Sprintf("2016-10-30 19:12:17,009 WARNING  LogMsgs: Sample warn 2. [%d]\n", goroutineID())
+	assert.Equal(expected, string(actual))
+
+	// Caller attributes. Format() called directly reports this test as the caller.
+	formatter, err = NewFormatterE("%(funcName)s %(filename)s:%(lineno)d %(pathname)s", nil)
+	assert.NoError(err)
+	formatter.DisableColors = true
+	_, file, line, _ := runtime.Caller(0)
+	actual, err = formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal(fmt.Sprintf("TestNewFormatterPython formatter_test.go:%d %s", line+1, file), string(actual))
+}
+
+func TestNewFormatterGroups(t *testing.T) {
+	name := func(e *logrus.Entry, _ *CustomFormatter) (interface{}, error) { return e.Data["name"], nil }
+	testCases := []struct {
+		template string
+		withName string
+		noName   string
+	}{
+		{"%[levelName]s:%{%[name]s:%}%[message]s", "WARNING:LogMsgs:Sample warn 2.", "WARNING:Sample warn 2."},
+		{"%{[%-10[name]s] %}%[message]s", "[LogMsgs   ] Sample warn 2.", "Sample warn 2."},
+		{"%{[%[custom]s] %}%[message]s", "[LogMsgs] Sample warn 2.", "Sample warn 2."},
+		{"%{(%[name]s %[.c]v)%}", "(LogMsgs 10)", "( 10)"},
+		{"%{<%{%[name]s%}|%{%[.x]s%}>%}", "<LogMsgs|>", ""},
+		{"%{static%}%{%[.x]s%}", "static", "static"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			formatter, err := NewFormatterE(tc.template, CustomHandlers{"custom": name})
+			assert.NoError(err)
+			formatter.DisableColors = true
+			entry := newBenchmarkEntry(formatter)
+
+			actual, err := formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal(tc.withName, string(actual))
+
+			delete(entry.Data, "name")
+			actual, err = formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal(tc.noName, string(actual))
+		})
+	}
+}
+
+func TestCustomFormatter_Templates(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%-7[levelName]s %[message]s%[fields]s", nil)
+	formatter.ForceColors = true
+	formatter.Templates = map[logrus.Level]string{logrus.ErrorLevel: "%-7[levelName]s|%-8[name]s|%[message]s%[fields]s"}
+	entry := newBenchmarkEntry(formatter)
+
+	// Levels without a template use the default one.
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[33mWARNING\033[0m Sample warn 2. \033[33ma\033[0m=b \033[33mc\033[0m=10 \033[33mname\033[0m=LogMsgs",
+		string(actual))
+
+	// Padding stays ANSI aware and the name field is only omitted when the level's template shows it.
+	entry.Level = logrus.ErrorLevel
+	actual, err = formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[31mERROR\033[0m  |LogMsgs |Sample warn 2. \033[31ma\033[0m=b \033[31mc\033[0m=10", string(actual))
+
+	// Changed templates are compiled again.
+	formatter.Templates[logrus.ErrorLevel] = "%[shortLevelName]s %[message]s"
+	actual, err = formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[31mERRO\033[0m Sample warn 2.", string(actual))
+
+	// Strict parsing.
+	err = formatter.ParseLevelTemplate(logrus.DebugLevel, "%[mesage]s")
+	assert.EqualError(err, "lcf: unknown attribute \"mesage\" at line 1 column 3 (offset 2)\n\t%[mesage]s\n\t  ^")
+	assert.NotContains(formatter.Templates, logrus.DebugLevel)
+	assert.NoError(formatter.ParseLevelTemplate(logrus.DebugLevel, "%[levelName]s"))
+	entry.Level = logrus.DebugLevel
+	actual, err = formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[36mDEBUG\033[0m", string(actual))
+
+	// Only the returned byte slice is allocated.
+	if raceEnabled {
+		return
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := formatter.Format(entry); err != nil {
+			panic(err)
+		}
+	})
+	assert.Equal(1.0, allocs)
+}
+
+func TestCustomFormatter_TemplatesStyle(t *testing.T) {
+	assert := require.New(t)
+	custom := CustomHandlers{"tag": func(*logrus.Entry, *CustomFormatter) (interface{}, error) { return "x", nil }}
+	formatter, err := NewFormatterStyle("{levelName} {message}", BraceStyle, custom)
+	assert.NoError(err)
+	formatter.DisableColors = true
+	assert.NoError(formatter.ParseLevelTemplate(logrus.WarnLevel, "{levelName:>9}|{tag}|{message}"))
+
+	actual, err := formatter.Format(newBenchmarkEntry(formatter))
+	assert.NoError(err)
+	assert.Equal("  WARNING|x|Sample warn 2.", string(actual))
+}
+
+func newBenchmarkEntry(formatter *CustomFormatter) *logrus.Entry {
+	logger := logrus.New()
+	logger.Formatter = formatter
+	entry := logrus.NewEntry(logger).WithFields(logrus.Fields{"name": "LogMsgs", "a": "b", "c": 10})
+	entry.Level = logrus.WarnLevel
+	entry.Message = "Sample warn 2."
+	entry.Time = time.Date(2016, 10, 30, 19, 12, 17, 149000000, time.UTC)
+	return entry
+}
+
+func TestCustomFormatter_FormatAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items at random with the race detector")
+	}
+	for _, forceColors := range []bool{false, true} {
+		t.Run(fmt.Sprintf("forceColors:%v", forceColors), func(t *testing.T) {
+			assert := require.New(t)
+			formatter := NewFormatter(Detailed, nil)
+			formatter.ForceColors = forceColors
+			entry := newBenchmarkEntry(formatter)
+
+			// Only the returned byte slice is allocated.
+			allocs := testing.AllocsPerRun(100, func() {
+				if _, err := formatter.Format(entry); err != nil {
+					panic(err)
+				}
+			})
+			assert.Equal(1.0, allocs)
+		})
+	}
+}
+
+func BenchmarkCustomFormatter_Format(b *testing.B) {
+	formatter := NewFormatter(Detailed, nil)
+	formatter.ForceColors = true
+	entry := newBenchmarkEntry(formatter)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := formatter.Format(entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Previous implementation for comparison: box every handler's value and let fmt.Sprintf() do the formatting.
+func BenchmarkCustomFormatter_FormatSprintf(b *testing.B) {
+	formatter := NewFormatter(Detailed, nil)
+	formatter.ForceColors = true
+	entry := newBenchmarkEntry(formatter)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		values := make([]interface{}, len(formatter.Handlers))
+		for j, handler := range formatter.Handlers {
+			value, err := handler(entry, formatter)
+			if err != nil {
+				b.Fatal(err)
+			}
+			values[j] = value
+		}
+		_ = bytes.NewBufferString(fmt.Sprintf(formatter.Template, values...)).Bytes()
+	}
+}
+
+func TestCustomFormatter_ForWriter(t *testing.T) {
+	assert := require.New(t)
+	defer setenv("NO_COLOR", "")()
+	defer setenv("CLICOLOR_FORCE", "")()
+	defer setenv("FORCE_COLOR", "")()
+	formatter := NewFormatter("%[levelName]s:%[message]s", nil)
+	formatter.ForceColors = true
+	entry := newBenchmarkEntry(formatter)
+
+	// Buffers are not terminals.
+	var buffer bytes.Buffer
+	plain := formatter.ForWriter(&buffer)
+	actual, err := plain.Format(entry)
+	assert.NoError(err)
+	assert.Equal("WARNING:Sample warn 2.", string(actual))
+	actual, err = formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[33mWARNING\033[0m:Sample warn 2.", string(actual))
+
+	// The environment decides for the writer, not the formatter's fields.
+	formatter.ForceColors = false
+	formatter.DisableColors = true
+	restore := setenv("FORCE_COLOR", "1")
+	forced := formatter.ForWriter(&buffer)
+	restore()
+	actual, err = forced.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[33mWARNING\033[0m:Sample warn 2.", string(actual))
+
+	// Changes to the formatter apply to the bound formatters.
+	assert.NoError(formatter.ParseTemplateE("%[shortLevelName]s %[message]s", nil))
+	formatter.ColorWarn = AnsiRed
+	actual, err = forced.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[31mWARN\033[0m Sample warn 2.", string(actual))
+	actual, err = plain.Format(entry)
+	assert.NoError(err)
+	assert.Equal("WARN Sample warn 2.", string(actual))
+	assert.True(formatter.DisableColors)
+}
+
+func TestNewFormatterWriter(t *testing.T) {
+	assert := require.New(t)
+	defer setenv("NO_COLOR", "")()
+	defer setenv("CLICOLOR_FORCE", "")()
+	defer setenv("FORCE_COLOR", "")()
+	defer setenv("TERM", "xterm")()
+
+	var buffer bytes.Buffer
+	formatter := NewFormatterWriter(&buffer, Basic, nil)
+	assert.False(formatter.ForceColors)
+	assert.True(formatter.DisableColors)
+	assert.Equal("%s:%s:%s%s\n", formatter.Template)
+
+	defer setenv("CLICOLOR_FORCE", "1")()
+	formatter = NewFormatterWriter(&buffer, Basic, nil)
+	assert.False(formatter.ForceColors)
+	assert.False(formatter.DisableColors)
+}
@@ -2,17 +2,15 @@ package lcf
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"regexp"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
-var _reBracketed = regexp.MustCompile(`%([\d.-]*)\[(\w+)](\w)`)
-
 // Handler is the function signature of formatting attributes such as "levelName" and "message".
 type Handler func(*logrus.Entry, *CustomFormatter) (interface{}, error)
 
@@ -37,53 +35,153 @@ func (a Attributes) Contains(attr string) bool {
 
 // HandlerAscTime returns the formatted timestamp of the entry.
 func HandlerAscTime(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
-	return entry.Time.Format(formatter.TimestampFormat), nil
+	return formatter.colorize(formatter.ColorTimestamp, entry.Time.Format(formatter.TimestampFormat)), nil
 }
 
-// HandlerFields returns the entry's fields (excluding name field if %[name]s is used) colorized according to log level.
-// Fields' formatting: key=value key2=value2
-func HandlerFields(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
-	var fields string
-
-	// Without sorting no need to get keys from map into a string array.
-	if formatter.DisableSorting {
-		for key, value := range entry.Data {
-			if key == "name" && formatter.Attributes.Contains("name") {
-				continue
-			}
-			fields = fmt.Sprintf("%s %s=%v", fields, Color(entry, formatter, key), value)
+// HandlerCreated returns the entry's timestamp as the number of seconds since the Unix epoch (e.g. 1477854737.149).
+func HandlerCreated(entry *logrus.Entry, _ *CustomFormatter) (interface{}, error) {
+	return float64(entry.Time.UnixNano()) / float64(time.Second), nil
+}
+
+// HandlerFileName returns the base name of the source file that logged the entry (e.g. "main.go").
+func HandlerFileName(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
+	frame, _ := callerFrame(entry)
+	return formatter.colorize(formatter.ColorCaller, callerFileName(frame)), nil
+}
+
+// HandlerFuncName returns the name of the function that logged the entry (e.g. "main").
+func HandlerFuncName(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
+	frame, _ := callerFrame(entry)
+	return formatter.colorize(formatter.ColorCaller, funcName(frame.Function)), nil
+}
+
+// HandlerField returns a Handler for the field-reference attribute of a key (e.g. "%[field:request_id]s" or
+// "%[.request_id]s"). The Handler returns the field's value, as text if it has a ValueRenderer, colored with
+// ColorFieldValue. Other values (e.g. numbers for %03d) are colored when formatted, and a missing field is formatted as
+// padding only whatever the verb is.
+//
+// :param key: Key of the field in entry.Data.
+func HandlerField(key string) Handler {
+	return func(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
+		if _, ok := entry.Data[key]; !ok {
+			return fieldValue{missing: true}, nil
+		}
+		value := renderedField(formatter, entry, key)
+		if text, ok := value.(string); ok {
+			return formatter.colorize(formatter.ColorFieldValue, text), nil
+		}
+		if !formatter.colorsEnabled() || formatter.ColorFieldValue == AnsiReset {
+			return value, nil
+		}
+		return fieldValue{value: value, color: string(appendSGR(nil, formatter.ColorFieldValue, formatter.Palette))}, nil
+	}
+}
+
+// fieldValue is a non-string or missing field value returned by HandlerField. It implements fmt.Formatter to write
+// the value with the directive's verb wrapped in color codes that padding is kept out of.
+type fieldValue struct {
+	value   interface{}
+	color   string // SGR sequence of ColorFieldValue.
+	missing bool
+}
+
+// Format writes the value formatted with the verb, flags, width and precision of the directive.
+func (v fieldValue) Format(state fmt.State, verb rune) {
+	width, hasWidth := state.Width()
+	if v.missing {
+		if hasWidth {
+			io.WriteString(state, strings.Repeat(" ", width))
 		}
-		return fields, nil
+		return
 	}
 
-	// Put keys in a string array and sort it.
-	keys := make([]string, len(entry.Data))
-	i := 0
-	for k := range entry.Data {
-		keys[i] = k
-		i++
+	// Zero padding is part of the number. Other padding is added outside of the color codes.
+	directive := []byte{'%'}
+	for _, flag := range []byte("+-# 0") {
+		if state.Flag(int(flag)) {
+			directive = append(directive, flag)
+		}
+	}
+	zeroPadded := state.Flag('0') && !state.Flag('-')
+	if hasWidth && zeroPadded {
+		directive = strconv.AppendInt(directive, int64(width), 10)
+	}
+	if precision, ok := state.Precision(); ok {
+		directive = append(directive, '.')
+		directive = strconv.AppendInt(directive, int64(precision), 10)
 	}
-	sort.Strings(keys)
+	directive = append(directive, string(verb)...)
+	text := fmt.Sprintf(string(directive), v.value)
 
-	// Do the rest.
-	for _, key := range keys {
-		if key == "name" && formatter.Attributes.Contains("name") {
-			continue
+	padding := ""
+	if hasWidth && !zeroPadded {
+		if n := width - displayWidth([]byte(text), false); n > 0 {
+			padding = strings.Repeat(" ", n)
 		}
-		fields = fmt.Sprintf("%s %s=%v", fields, Color(entry, formatter, key), entry.Data[key])
 	}
-	return fields, nil
+	if !state.Flag('-') {
+		io.WriteString(state, padding)
+	}
+	io.WriteString(state, v.color+text+"\033[0m")
+	if state.Flag('-') {
+		io.WriteString(state, padding)
+	}
+}
+
+// HandlerFields returns the entry's fields (excluding name field if %[name]s is used, fields shown with
+// field-reference attributes and fields hidden by FieldOrder) with keys colorized according to log level or
+// ColorFieldKey.
+// Fields' formatting: key=value key2="quoted value" (see FieldQuoting)
+func HandlerFields(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
+	var fieldColumns map[string]bool
+	if formatter.compiled != nil {
+		fieldColumns = formatter.compiled.fieldColumns
+	}
+	return fieldsHandler(fieldColumns)(entry, formatter)
+}
+
+// fieldsHandler returns the Handler of %[fields]s omitting the fields shown by their own attribute in a template.
+func fieldsHandler(fieldColumns map[string]bool) Handler {
+	return func(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
+		s := getRenderState()
+		defer putRenderState(s)
+		s.fieldColumns = fieldColumns
+		appendFields(s, entry, formatter)
+		return s.buffer.String(), nil
+	}
+}
+
+// HandlerGoroutine returns the ID of the goroutine formatting the entry, which is usually the one that logged it.
+func HandlerGoroutine(_ *logrus.Entry, _ *CustomFormatter) (interface{}, error) {
+	return goroutineID(), nil
+}
+
+// HandlerLevelCode returns the single letter code of the entry's level (e.g. "W").
+func HandlerLevelCode(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
+	return Color(entry, formatter, formatter.levelStyle(entry.Level).Code), nil
 }
 
 // HandlerLevelName returns the entry's long level name (e.g. "WARNING").
 func HandlerLevelName(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
-	return Color(entry, formatter, strings.ToUpper(entry.Level.String())), nil
+	return Color(entry, formatter, formatter.levelStyle(entry.Level).Name), nil
+}
+
+// HandlerLineNo returns the source line number that logged the entry.
+func HandlerLineNo(entry *logrus.Entry, _ *CustomFormatter) (interface{}, error) {
+	frame, _ := callerFrame(entry)
+	return frame.Line, nil
+}
+
+// HandlerModule returns the last element of the import path of the package that logged the entry (e.g. "main").
+func HandlerModule(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
+	frame, _ := callerFrame(entry)
+	return formatter.colorize(formatter.ColorCaller, callerModule(frame)), nil
 }
 
 // HandlerName returns the name field value set by the user in entry.Data.
-func HandlerName(entry *logrus.Entry, _ *CustomFormatter) (interface{}, error) {
+func HandlerName(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
 	if value, ok := entry.Data["name"]; ok {
-		return value.(string), nil
+		return formatter.colorize(formatter.ColorName, value.(string)), nil
 	}
 	return "", nil
 }
@@ -93,6 +191,17 @@ func HandlerMessage(entry *logrus.Entry, _ *CustomFormatter) (interface{}, error
 	return entry.Message, nil
 }
 
+// HandlerMsecs returns the millisecond portion of the entry's timestamp.
+func HandlerMsecs(entry *logrus.Entry, _ *CustomFormatter) (interface{}, error) {
+	return entry.Time.Nanosecond() / int(time.Millisecond), nil
+}
+
+// HandlerPathName returns the full path of the source file that logged the entry.
+func HandlerPathName(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
+	frame, _ := callerFrame(entry)
+	return formatter.colorize(formatter.ColorCaller, frame.File), nil
+}
+
 // HandlerProcess returns the current process' PID.
 func HandlerProcess(_ *logrus.Entry, _ *CustomFormatter) (interface{}, error) {
 	return os.Getpid(), nil
@@ -103,75 +212,276 @@ func HandlerRelativeCreated(_ *logrus.Entry, formatter *CustomFormatter) (interf
 	return int(time.Since(formatter.startTime) / time.Second), nil
 }
 
-// HandlerShortLevelName returns the first 4 letters of the entry's level name (e.g. "WARN").
+// HandlerRelativeMsecs returns the number of milliseconds since program start time, like Python's relativeCreated.
+func HandlerRelativeMsecs(_ *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
+	return int(time.Since(formatter.startTime) / time.Millisecond), nil
+}
+
+// HandlerShortLevelName returns the entry's short level name, by default the first 4 letters of its level name (e.g.
+// "WARN").
 func HandlerShortLevelName(entry *logrus.Entry, formatter *CustomFormatter) (interface{}, error) {
-	return Color(entry, formatter, strings.ToUpper(entry.Level.String()[:4])), nil
+	return Color(entry, formatter, formatter.levelStyle(entry.Level).ShortName), nil
 }
 
-// ParseTemplate parses the template string and prepares it for fmt.Sprintf() and keeps track of which handlers to use.
-//
-// :param template: Pre-processed formatting template (e.g. "%[message]s\n").
-//
-// :param custom: User-defined formatters evaluated before built-in formatters. Keys are attributes to look for in the
-func (f *CustomFormatter) ParseTemplate(template string, custom CustomHandlers) {
-	f.Attributes = make(Attributes)
-	segments := []string{}
-	segmentsPos := 0
-
-	for _, idxs := range _reBracketed.FindAllStringSubmatchIndex(template, -1) {
-		// Find attribute names to replace and with what handler function to map them to.
-		attribute := template[idxs[4]:idxs[5]]
-		if fn, ok := custom[attribute]; ok {
-			f.Handlers = append(f.Handlers, fn)
-		} else {
-			switch attribute {
-			case "ascTime":
-				f.Handlers = append(f.Handlers, HandlerAscTime)
-			case "fields":
-				f.Handlers = append(f.Handlers, HandlerFields)
-			case "levelName":
-				f.Handlers = append(f.Handlers, HandlerLevelName)
-			case "name":
-				f.Handlers = append(f.Handlers, HandlerName)
-			case "message":
-				f.Handlers = append(f.Handlers, HandlerMessage)
-			case "process":
-				f.Handlers = append(f.Handlers, HandlerProcess)
-			case "relativeCreated":
-				f.Handlers = append(f.Handlers, HandlerRelativeCreated)
-			case "shortLevelName":
-				f.Handlers = append(f.Handlers, HandlerShortLevelName)
-			default:
-				continue
-			}
+func appendAscTime(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	if formatter.ColorTimestamp != AnsiReset && formatter.colorsEnabled() {
+		s.scratch = appendSGR(s.scratch[:0], formatter.ColorTimestamp, formatter.Palette)
+		s.scratch = entry.Time.AppendFormat(s.scratch, formatter.TimestampFormat)
+		s.scratch = append(s.scratch, "\033[0m"...)
+	} else {
+		s.scratch = entry.Time.AppendFormat(s.scratch[:0], formatter.TimestampFormat)
+	}
+	s.buffer.Write(s.scratch)
+}
+
+func appendFields(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	s.keys = s.keys[:0]
+	for key := range entry.Data {
+		if s.fieldColumns[key] || !formatter.FieldOrder.shows(key) {
+			continue
 		}
-		f.Attributes[attribute] = true
+		s.keys = append(s.keys, key)
+	}
 
-		// Add segments of the template that do not match regexp (between attributes).
-		if segmentsPos < idxs[0] {
-			segments = append(segments, template[segmentsPos:idxs[0]])
+	// Without sorting keys not in FieldOrder.First stay in random map order.
+	formatter.FieldOrder.sort(s.keys, formatter.DisableSorting)
+
+	for _, key := range s.keys {
+		if formatter.Flatten.Enabled {
+			formatter.appendFlattened(s, entry.Level, key, entry.Data[key])
+		} else {
+			formatter.appendFieldPair(s, entry.Level, key, entry.Data[key])
 		}
+	}
+}
+
+// appendFieldPair writes one " key=value" pair of %[fields]s.
+func (f *CustomFormatter) appendFieldPair(s *renderState, level logrus.Level, key string, value interface{}) {
+	s.buffer.WriteByte(' ')
+	f.writeStyle(s, f.fieldKeyColor(level), f.FieldQuoting.key(key))
+	s.buffer.WriteByte('=')
+	f.appendFieldValue(s, key, value)
+}
+
+// appendFieldValue writes a value of %[fields]s rendered by its ValueRenderer and quoted according to FieldQuoting in
+// the field value color.
+func (f *CustomFormatter) appendFieldValue(s *renderState, key string, value interface{}) {
+	colored := f.ColorFieldValue != AnsiReset && f.colorsEnabled()
+	if colored {
+		s.scratch = appendSGR(s.scratch[:0], f.ColorFieldValue, f.Palette)
+		s.buffer.Write(s.scratch)
+	}
+	start := s.buffer.Len()
+	f.appendRendered(s, key, value)
+	f.FieldQuoting.quoteValue(s, start)
+	if colored {
+		s.buffer.WriteString("\033[0m")
+	}
+}
+
+func appendFileName(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	frame, _ := s.caller(entry)
+	formatter.writeStyle(s, formatter.ColorCaller, callerFileName(frame))
+}
+
+func appendFuncName(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	frame, _ := s.caller(entry)
+	formatter.writeStyle(s, formatter.ColorCaller, funcName(frame.Function))
+}
 
-		// Keep track of padded (y-x > 0) string (== 's') attributes for ANSI color handling.
-		if template[idxs[6]:idxs[7]] == "s" && idxs[3]-idxs[2] > 0 {
-			start := 0
-			for _, s := range segments {
-				start += len(s)
-			}
-			end := start + idxs[3] - idxs[0] + idxs[7] - idxs[6]
-			f.handleColors = append(f.handleColors, [...]int{len(f.Handlers) - 1, start, end})
+// appendField returns the appender of a field-reference attribute.
+func appendField(key string) appender {
+	return func(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+		value, ok := entry.Data[key]
+		if !ok {
+			return
+		}
+		if formatter.ColorFieldValue == AnsiReset || !formatter.colorsEnabled() {
+			formatter.appendRendered(s, key, value)
+			return
 		}
+		start := s.buffer.Len()
+		s.scratch = appendSGR(s.scratch[:0], formatter.ColorFieldValue, formatter.Palette)
+		s.buffer.Write(s.scratch)
+		valueStart := s.buffer.Len()
+		formatter.appendRendered(s, key, value)
+		if s.buffer.Len() == valueStart {
+			s.buffer.Truncate(start) // Empty values stay empty for conditional groups.
+			return
+		}
+		s.buffer.WriteString("\033[0m")
+	}
+}
+
+func appendGoroutine(s *renderState, _ *logrus.Entry, _ *CustomFormatter) {
+	s.scratch = strconv.AppendInt(s.scratch[:0], int64(goroutineID()), 10)
+	s.buffer.Write(s.scratch)
+}
+
+func appendLevelCode(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	style := formatter.levelStyle(entry.Level)
+	formatter.writeStyle(s, style.Color, style.Code)
+}
+
+func appendLevelName(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	style := formatter.levelStyle(entry.Level)
+	formatter.writeStyle(s, style.Color, style.Name)
+}
+
+func appendLineNo(s *renderState, entry *logrus.Entry, _ *CustomFormatter) {
+	frame, _ := s.caller(entry)
+	s.scratch = strconv.AppendInt(s.scratch[:0], int64(frame.Line), 10)
+	s.buffer.Write(s.scratch)
+}
 
-		// Update segments.
-		segments = append(segments, template[idxs[0]:idxs[3]]+template[idxs[6]:idxs[7]])
-		segmentsPos = idxs[1]
+func appendModule(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	frame, _ := s.caller(entry)
+	formatter.writeStyle(s, formatter.ColorCaller, callerModule(frame))
+}
+
+func appendName(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	if value, ok := entry.Data["name"]; ok {
+		formatter.writeStyle(s, formatter.ColorName, value.(string))
+	}
+}
+
+func appendMessage(s *renderState, entry *logrus.Entry, _ *CustomFormatter) {
+	s.buffer.WriteString(entry.Message)
+}
+
+func appendMsecs(s *renderState, entry *logrus.Entry, _ *CustomFormatter) {
+	s.scratch = strconv.AppendInt(s.scratch[:0], int64(entry.Time.Nanosecond()/int(time.Millisecond)), 10)
+	s.buffer.Write(s.scratch)
+}
+
+func appendPathName(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	frame, _ := s.caller(entry)
+	formatter.writeStyle(s, formatter.ColorCaller, frame.File)
+}
+
+func appendProcess(s *renderState, _ *logrus.Entry, _ *CustomFormatter) {
+	s.scratch = strconv.AppendInt(s.scratch[:0], int64(os.Getpid()), 10)
+	s.buffer.Write(s.scratch)
+}
+
+func appendRelativeCreated(s *renderState, _ *logrus.Entry, formatter *CustomFormatter) {
+	s.scratch = strconv.AppendInt(s.scratch[:0], int64(time.Since(formatter.startTime)/time.Second), 10)
+	s.buffer.Write(s.scratch)
+}
+
+func appendRelativeMsecs(s *renderState, _ *logrus.Entry, formatter *CustomFormatter) {
+	s.scratch = strconv.AppendInt(s.scratch[:0], int64(time.Since(formatter.startTime)/time.Millisecond), 10)
+	s.buffer.Write(s.scratch)
+}
+
+func appendShortLevelName(s *renderState, entry *logrus.Entry, formatter *CustomFormatter) {
+	style := formatter.levelStyle(entry.Level)
+	formatter.writeStyle(s, style.Color, style.ShortName)
+}
+
+// lookupAppender returns the appender of a built-in attribute and the kind of value it writes ('s' for strings and 'd'
+// for integers). Returns nil if the attribute has no appender.
+func lookupAppender(attribute string) (appender, rune) {
+	switch attribute {
+	case "ascTime":
+		return appendAscTime, 's'
+	case "fields":
+		return appendFields, 's'
+	case "fileName":
+		return appendFileName, 's'
+	case "funcName":
+		return appendFuncName, 's'
+	case "goroutine":
+		return appendGoroutine, 'd'
+	case "levelCode":
+		return appendLevelCode, 's'
+	case "levelName":
+		return appendLevelName, 's'
+	case "lineNo":
+		return appendLineNo, 'd'
+	case "module":
+		return appendModule, 's'
+	case "name":
+		return appendName, 's'
+	case "message":
+		return appendMessage, 's'
+	case "msecs":
+		return appendMsecs, 'd'
+	case "pathName":
+		return appendPathName, 's'
+	case "process":
+		return appendProcess, 'd'
+	case "relativeCreated":
+		return appendRelativeCreated, 'd'
+	case "relativeMsecs":
+		return appendRelativeMsecs, 'd'
+	case "shortLevelName":
+		return appendShortLevelName, 's'
 	}
+	if key, ok := fieldKey(attribute); ok {
+		return appendField(key), 's'
+	}
+	return nil, 0
+}
 
-	// Add trailing segments of the template that did not match the regexp (newline).
-	if segmentsPos < len(template) {
-		segments = append(segments, template[segmentsPos:])
+// fieldKey returns the entry.Data key of a field-reference attribute (e.g. "request_id" from "field:request_id" or
+// ".request_id"). Returns false if the attribute is not a field reference.
+func fieldKey(attribute string) (string, bool) {
+	var key string
+	switch {
+	case strings.HasPrefix(attribute, "field:"):
+		key = attribute[len("field:"):]
+	case strings.HasPrefix(attribute, "."):
+		key = attribute[1:]
 	}
+	return key, key != ""
+}
 
-	// Join segments.
-	f.Template = strings.Join(segments, "")
+// lookupHandler returns the Handler for an attribute name. Custom handlers take precedence over built-in ones.
+func lookupHandler(attribute string, custom CustomHandlers) (Handler, bool) {
+	if fn, ok := custom[attribute]; ok {
+		return fn, true
+	}
+	switch attribute {
+	case "ascTime":
+		return HandlerAscTime, true
+	case "created":
+		return HandlerCreated, true
+	case "fields":
+		return HandlerFields, true
+	case "fileName":
+		return HandlerFileName, true
+	case "funcName":
+		return HandlerFuncName, true
+	case "goroutine":
+		return HandlerGoroutine, true
+	case "levelCode":
+		return HandlerLevelCode, true
+	case "levelName":
+		return HandlerLevelName, true
+	case "lineNo":
+		return HandlerLineNo, true
+	case "module":
+		return HandlerModule, true
+	case "name":
+		return HandlerName, true
+	case "message":
+		return HandlerMessage, true
+	case "msecs":
+		return HandlerMsecs, true
+	case "pathName":
+		return HandlerPathName, true
+	case "process":
+		return HandlerProcess, true
+	case "relativeCreated":
+		return HandlerRelativeCreated, true
+	case "relativeMsecs":
+		return HandlerRelativeMsecs, true
+	case "shortLevelName":
+		return HandlerShortLevelName, true
+	}
+	if key, ok := fieldKey(attribute); ok {
+		return HandlerField(key), true
+	}
+	return nil, false
 }
@@ -1,7 +1,10 @@
 package lcf
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -55,6 +58,91 @@ func TestHandlerAscTime(t *testing.T) {
 	assert.Regexp(`^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d\.\d{3}$`, actual)
 }
 
+func TestHandlerCreatedMsecs(t *testing.T) {
+	assert := require.New(t)
+
+	// Setup.
+	formatter := NewFormatter("", nil)
+	entry := logrus.NewEntry(logrus.New())
+	entry.Time = time.Unix(1477854737, 149000000)
+
+	// Test.
+	created, err := HandlerCreated(entry, formatter)
+	assert.NoError(err)
+	assert.InDelta(1477854737.149, created.(float64), 0.0001)
+	msecs, err := HandlerMsecs(entry, formatter)
+	assert.NoError(err)
+	assert.Equal(149, msecs.(int))
+}
+
+func TestHandlerField(t *testing.T) {
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{"%-10[field:request_id]s|%[fields]s", "abc123    | a=1 tenant=acme"},
+		{"%6[.tenant]s|%[.missing]s|%[fields]s", "  acme|| a=1 request_id=abc123"},
+		{"%03[.a]d %[.a]v %[field:a]x|%[fields]s", "001 1 1| request_id=abc123 tenant=acme"},
+		{"%05[.missing]d|%-3[.missing]x|%{(%[.missing]d)%}|%[.a]s", "     |   ||1"},
+		{"%[field:]s %[.]s|%[fields]s", "%[field:]s %[.]s| a=1 request_id=abc123 tenant=acme"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			formatter := NewFormatter(tc.template, nil)
+			formatter.DisableColors = true
+			entry := logrus.NewEntry(logrus.New())
+			entry.Data = logrus.Fields{"request_id": "abc123", "tenant": "acme", "a": 1}
+
+			actual, err := formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal(tc.expected, string(actual))
+		})
+	}
+
+	// Field references are errors in strict mode only if the key is empty.
+	_, err := NewFormatterE("%[field:]s", nil)
+	assert := require.New(t)
+	assert.EqualError(err, "lcf: unknown attribute \"field:\" at line 1 column 3 (offset 2)\n\t%[field:]s\n\t  ^")
+
+	// Custom handlers take precedence and do not hide the field.
+	custom := CustomHandlers{".tenant": func(*logrus.Entry, *CustomFormatter) (interface{}, error) { return "x", nil }}
+	formatter := NewFormatter("%[.tenant]s|%[fields]s", custom)
+	entry := logrus.NewEntry(logrus.New())
+	entry.Data = logrus.Fields{"tenant": "acme"}
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("x| tenant=acme", string(actual))
+}
+
+func TestHandlerField_Colors(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%-8[.tenant]s|%[.a]v|%03[.a]d|%-3[.a]d|%3[.a]x|%-4[field:x]s|%{[%[.empty]s]%}|%[fields]s",
+		nil)
+	formatter.ForceColors = true
+	formatter.ColorFieldKey = AnsiBlue
+	formatter.ColorFieldValue = AnsiCyan
+	entry := logrus.NewEntry(logrus.New())
+	entry.Data = logrus.Fields{"tenant": "acme", "a": 1, "empty": "", "b": "c"}
+
+	// Padding excludes the color codes except zero padding of numbers.
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[36macme\033[0m    |\033[36m1\033[0m|\033[36m001\033[0m|\033[36m1\033[0m  |  \033[36m1\033[0m"+
+		"|    || \033[34mb\033[0m=\033[36mc\033[0m", string(actual))
+
+	value, err := HandlerField("tenant")(entry, formatter)
+	assert.NoError(err)
+	assert.Equal("\033[36macme\033[0m", value)
+	value, err = HandlerField("a")(entry, formatter)
+	assert.NoError(err)
+	assert.Equal("\033[36m001\033[0m|\033[36m+1\033[0m  ", fmt.Sprintf("%03d|%+-4[1]d", value))
+	value, err = HandlerField("missing")(entry, formatter)
+	assert.NoError(err)
+	assert.Equal("|  ", fmt.Sprintf("%v|%2d", value, value))
+}
+
 func TestHandlerFields(t *testing.T) {
 	assert := require.New(t)
 
@@ -112,6 +200,27 @@ func TestHandlerRelativeCreated(t *testing.T) {
 	assert.True(values[0] < values[1])
 }
 
+func TestHandlerRelativeMsecs(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%[relativeMsecs]d|%(relativeCreated)d", nil)
+	formatter.startTime = time.Now().Add(-1500 * time.Millisecond)
+
+	value, err := HandlerRelativeMsecs(nil, formatter)
+	assert.NoError(err)
+	assert.InDelta(1500, value.(int), 1000)
+
+	// Python's relativeCreated is in milliseconds too.
+	actual, err := formatter.Format(logrus.NewEntry(logrus.New()))
+	assert.NoError(err)
+	parts := strings.Split(string(actual), "|")
+	assert.Len(parts, 2)
+	for _, part := range parts {
+		msecs, err := strconv.Atoi(part)
+		assert.NoError(err)
+		assert.InDelta(1500, msecs, 1000)
+	}
+}
+
 func ExampleCustomHandlers() {
 	// Define your own handler for new or to override built-in attributes. Here we'll
 	// define LoadAverage() to handle a new %[loadAvg]f attribute.
@@ -0,0 +1,282 @@
+package lcf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// htmlRole is a CSS class given to text colored with one of a formatter's colors (e.g. "lcf-warn" for ColorWarn).
+type htmlRole struct {
+	class string
+	sgr   string // SGR sequence of the color as written by the formatter.
+}
+
+// HTMLWriter converts colored output of formatters to HTML. Text is written into a <pre class="lcf"> block so padding
+// stays aligned, and every colored run becomes a <span>. Spans get CSS classes for their colors and text attributes
+// (e.g. "lcf-yellow lcf-bold", see HTMLStyleSheet) and for the formatter colors they match (e.g. "lcf-warn" for
+// ColorWarn or "lcf-timestamp" for ColorTimestamp), so levels and attributes can be styled separately. 256 and 24-bit
+// colors are written as inline styles. Other escape sequences are removed, also when split between Write calls.
+//
+// Roles are told apart by their colors only. Colors shared by several formatter colors (e.g. ColorFatal and
+// ColorPanic both red) get none of their classes, and field keys colored with the level color (ColorFieldKey is
+// AnsiReset) get the level's class. Give every formatter color a unique style to get a class for each.
+//
+// Call Close to end the <pre> block. Like most writers it is not safe for concurrent use.
+type HTMLWriter struct {
+	w       io.Writer
+	roles   []htmlRole
+	palette Palette
+	parser  escapeParser
+	buffer  []byte
+	style   Style // Style of the following text.
+	span    Style // Style of the open span.
+	open    bool  // A span is open.
+	started bool  // The <pre> tag was written.
+}
+
+// NewHTMLWriter returns an HTMLWriter writing HTML to w.
+//
+// :param w: Writer the HTML is written to.
+//
+// :param formatter: Formatter whose colors are matched to CSS classes such as "lcf-warn". May be nil.
+func NewHTMLWriter(w io.Writer, formatter *CustomFormatter) *HTMLWriter {
+	h := &HTMLWriter{w: w}
+	if formatter == nil {
+		return h
+	}
+	h.palette = formatter.Palette
+	colors := []struct {
+		class string
+		style Style
+	}{
+		{"lcf-trace", formatter.ColorTrace},
+		{"lcf-debug", formatter.ColorDebug},
+		{"lcf-info", formatter.ColorInfo},
+		{"lcf-warn", formatter.ColorWarn},
+		{"lcf-error", formatter.ColorError},
+		{"lcf-fatal", formatter.ColorFatal},
+		{"lcf-panic", formatter.ColorPanic},
+		{"lcf-field-key", formatter.ColorFieldKey},
+		{"lcf-field-value", formatter.ColorFieldValue},
+		{"lcf-timestamp", formatter.ColorTimestamp},
+		{"lcf-name", formatter.ColorName},
+		{"lcf-caller", formatter.ColorCaller},
+	}
+	for _, color := range colors {
+		if color.style != AnsiReset {
+			h.roles = append(h.roles, htmlRole{color.class, string(appendSGR(nil, color.style, h.palette))})
+		}
+	}
+
+	// Colors shared by several roles (e.g. ColorFatal and ColorPanic) do not tell which one wrote the text.
+	count := make(map[string]int, len(h.roles))
+	for _, role := range h.roles {
+		count[role.sgr]++
+	}
+	unique := h.roles[:0]
+	for _, role := range h.roles {
+		if count[role.sgr] == 1 {
+			unique = append(unique, role)
+		}
+	}
+	h.roles = unique
+	return h
+}
+
+// Write converts p to HTML and writes it. Returns len(p) if the HTML could be written.
+func (h *HTMLWriter) Write(p []byte) (int, error) {
+	h.buffer = h.buffer[:0]
+	if !h.started {
+		h.buffer = append(h.buffer, `<pre class="lcf">`...)
+		h.started = true
+	}
+	for _, c := range p {
+		switch h.parser.next(c) {
+		case escapeSGR:
+			h.style = applySGR(h.style, h.parser.params)
+		case escapeText:
+			h.writeText(c)
+		}
+	}
+	if _, err := h.w.Write(h.buffer); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close ends the open span and the <pre> block. It does not close the underlying writer.
+func (h *HTMLWriter) Close() error {
+	h.buffer = h.buffer[:0]
+	if !h.started {
+		h.buffer = append(h.buffer, `<pre class="lcf">`...)
+		h.started = true
+	}
+	if h.open {
+		h.buffer = append(h.buffer, "</span>"...)
+		h.open = false
+	}
+	h.buffer = append(h.buffer, "</pre>"...)
+	_, err := h.w.Write(h.buffer)
+	return err
+}
+
+// writeText adds one byte of text to the buffer, opening or closing spans if the style changed.
+func (h *HTMLWriter) writeText(c byte) {
+	if h.open && h.span != h.style {
+		h.buffer = append(h.buffer, "</span>"...)
+		h.open = false
+	}
+	if !h.open && h.style != AnsiReset {
+		h.buffer = h.appendSpan(h.buffer, h.style)
+		h.span, h.open = h.style, true
+	}
+	switch c {
+	case '<':
+		h.buffer = append(h.buffer, "&lt;"...)
+	case '>':
+		h.buffer = append(h.buffer, "&gt;"...)
+	case '&':
+		h.buffer = append(h.buffer, "&amp;"...)
+	default:
+		h.buffer = append(h.buffer, c)
+	}
+}
+
+// appendSpan appends the opening span tag of a style.
+func (h *HTMLWriter) appendSpan(b []byte, style Style) []byte {
+	var classes, inline []byte
+	class := func(name string) {
+		if len(classes) > 0 {
+			classes = append(classes, ' ')
+		}
+		classes = append(classes, name...)
+	}
+	sgr := string(appendSGR(nil, style, h.palette))
+	for _, role := range h.roles {
+		if role.sgr == sgr {
+			class(role.class)
+		}
+	}
+	for i, name := range attributeWords {
+		if style&(StyleBold<<uint(i)) != 0 {
+			class("lcf-" + name)
+		}
+	}
+	for _, color := range [...]struct {
+		value    Style
+		class    string
+		property string
+	}{
+		{style & styleColorMask, "lcf-", "color"},
+		{style >> styleBackground & styleColorMask, "lcf-bg-", "background-color"},
+	} {
+		value := int(color.value & styleValueMask)
+		switch int(color.value >> styleValueBits & styleKindMask) {
+		case colorBasic:
+			if value != 0 {
+				class(color.class + basicColorNames[basicIndex(value)])
+			}
+			continue
+		case color256:
+			value = paletteRGB(value)
+		}
+		inline = append(inline, fmt.Sprintf("%s:#%06x;", color.property, value)...)
+	}
+	b = append(b, "<span"...)
+	if len(classes) > 0 {
+		b = append(b, ` class="`...)
+		b = append(b, classes...)
+		b = append(b, '"')
+	}
+	if len(inline) > 0 {
+		b = append(b, ` style="`...)
+		b = append(b, inline...)
+		b = append(b, '"')
+	}
+	return append(b, '>')
+}
+
+// applySGR returns the style after the parameters of an SGR sequence (e.g. "1;31" of "\033[1;31m") are applied to it.
+func applySGR(style Style, params []byte) Style {
+	codes := make([]int, 0, 8)
+	for _, field := range bytes.FieldsFunc(params, func(r rune) bool { return r == ';' || r == ':' }) {
+		code, _ := strconv.Atoi(string(field))
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return AnsiReset
+	}
+	for i := 0; i < len(codes); i++ {
+		switch code := codes[i]; {
+		case code == 0:
+			style = AnsiReset
+		case 1 <= code && code <= 4:
+			style |= StyleBold << uint(code-1)
+		case code == 22:
+			style &^= StyleBold | StyleDim
+		case code == 23:
+			style &^= StyleItalic
+		case code == 24:
+			style &^= StyleUnderline
+		case 30 <= code && code <= 37, 90 <= code && code <= 97:
+			style = style&^styleColorMask | Style(code)
+		case code == 39:
+			style &^= styleColorMask
+		case 40 <= code && code <= 47, 100 <= code && code <= 107:
+			style = style.On(Style(code - 10))
+		case code == 49:
+			style = style.On(AnsiReset)
+		case code == 38 || code == 48:
+			var color Style
+			switch {
+			case i+2 < len(codes) && codes[i+1] == 5:
+				color = Color256(uint8(codes[i+2]))
+				i += 2
+			case i+4 < len(codes) && codes[i+1] == 2:
+				color = RGB(uint8(codes[i+2]), uint8(codes[i+3]), uint8(codes[i+4]))
+				i += 4
+			default:
+				return style
+			}
+			if code == 38 {
+				style = style&^styleColorMask | color
+			} else {
+				style = style.On(color)
+			}
+		}
+	}
+	return style
+}
+
+// ANSIToHTML converts colored output of formatters to a <pre> block of HTML. See HTMLWriter.
+//
+// :param text: Formatted log lines with ANSI color sequences.
+//
+// :param formatter: Formatter whose colors are matched to CSS classes such as "lcf-warn". May be nil.
+func ANSIToHTML(text string, formatter *CustomFormatter) string {
+	var buffer bytes.Buffer
+	h := NewHTMLWriter(&buffer, formatter)
+	h.Write([]byte(text))
+	h.Close()
+	return buffer.String()
+}
+
+// HTMLStyleSheet returns CSS for the classes written by HTMLWriter, with the colors xterm uses on a black background.
+// Rules for formatter color classes such as ".lcf-warn" can be added to it.
+func HTMLStyleSheet() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("pre.lcf { background-color: #000000; color: #e5e5e5; }\n")
+	buffer.WriteString(".lcf-bold { font-weight: bold; }\n")
+	buffer.WriteString(".lcf-dim { opacity: 0.6; }\n")
+	buffer.WriteString(".lcf-italic { font-style: italic; }\n")
+	buffer.WriteString(".lcf-underline { text-decoration: underline; }\n")
+	for index, name := range basicColorNames {
+		fmt.Fprintf(&buffer, ".lcf-%s { color: #%06x; }\n", name, basicRGB[index])
+	}
+	for index, name := range basicColorNames {
+		fmt.Fprintf(&buffer, ".lcf-bg-%s { background-color: #%06x; }\n", name, basicRGB[index])
+	}
+	return buffer.String()
+}
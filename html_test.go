@@ -0,0 +1,98 @@
+package lcf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestANSIToHTML(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{"", ""},
+		{"a <b> & c", "a &lt;b&gt; &amp; c"},
+		{"\033[31mERROR\033[0m x", `<span class="lcf-red">ERROR</span> x`},
+		{"\033[1;4;93;44mA\033[22mB\033[m", `<span class="lcf-bold lcf-underline lcf-hiyellow lcf-bg-blue">A</span>` +
+			`<span class="lcf-underline lcf-hiyellow lcf-bg-blue">B</span>`},
+		{"\033[38;5;208mA\033[48;2;0;0;200mB\033[39;49mC", `<span style="color:#ff8700;">A</span>` +
+			`<span style="color:#ff8700;background-color:#0000c8;">B</span>C`},
+		{"\033[3mA\033[23m\033[2mB\033[0m\033[mC", `<span class="lcf-italic">A</span><span class="lcf-dim">B</span>C`},
+		{"\033]0;title\a\033[2KA\033[38;5mB", "AB"},
+		{"\033[31m\033[0m\n", "\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.text), func(t *testing.T) {
+			assert := require.New(t)
+			assert.Equal(`<pre class="lcf">`+tc.expected+"</pre>", ANSIToHTML(tc.text, nil))
+
+			// Split at every offset to exercise sequences spanning Write calls.
+			for split := 0; split <= len(tc.text); split++ {
+				var buffer bytes.Buffer
+				h := NewHTMLWriter(&buffer, nil)
+				_, err := h.Write([]byte(tc.text[:split]))
+				assert.NoError(err)
+				_, err = h.Write([]byte(tc.text[split:]))
+				assert.NoError(err)
+				assert.NoError(h.Close())
+				assert.Equal(`<pre class="lcf">`+tc.expected+"</pre>", buffer.String(), "split at %d", split)
+			}
+		})
+	}
+}
+
+func TestHTMLWriter_Formatter(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%[ascTime]s %-8[levelName]s %[message]s%[fields]s\n", nil)
+	formatter.SetTheme(Theme{Warn: RGB(255, 135, 0).Bold(), Fatal: AnsiMagenta, Panic: AnsiMagenta, Timestamp: AnsiHiBlack})
+	formatter.Palette = Palette256
+	formatter.ForceColors = true
+	entry := newBenchmarkEntry(formatter)
+	delete(entry.Data, "c")
+	formatted, err := formatter.Format(entry)
+	assert.NoError(err)
+
+	var buffer bytes.Buffer
+	h := NewHTMLWriter(&buffer, formatter)
+	_, err = h.Write(formatted)
+	assert.NoError(err)
+	assert.NoError(h.Close())
+	assert.Equal(`<pre class="lcf"><span class="lcf-timestamp lcf-hiblack">2016-10-30 19:12:17.149</span> `+
+		`<span class="lcf-warn lcf-bold" style="color:#ff8700;">WARNING</span>  Sample warn 2. `+
+		`<span class="lcf-warn lcf-bold" style="color:#ff8700;">a</span>=b `+
+		`<span class="lcf-warn lcf-bold" style="color:#ff8700;">name</span>=LogMsgs`+"\n</pre>", buffer.String())
+
+	// Fatal and panic share a color so neither class is given.
+	assert.Equal(`<pre class="lcf"><span class="lcf-magenta">PANIC</span></pre>`,
+		ANSIToHTML("\033[35mPANIC\033[0m", formatter))
+
+	// Unique colors tell field keys from levels.
+	formatter.ColorFieldKey = AnsiBlue
+	formatter.ColorFatal = AnsiRed
+	assert.Equal(`<pre class="lcf"><span class="lcf-fatal lcf-red">FATAL</span> `+
+		`<span class="lcf-field-key lcf-blue">a</span>=b</pre>`,
+		ANSIToHTML("\033[31mFATAL\033[0m \033[34ma\033[0m=b", formatter))
+}
+
+func TestHTMLWriter_Error(t *testing.T) {
+	assert := require.New(t)
+	h := NewHTMLWriter(failingWriter{}, nil)
+	n, err := h.Write([]byte("text"))
+	assert.EqualError(err, "disk full")
+	assert.Equal(0, n)
+	assert.EqualError(h.Close(), "disk full")
+}
+
+func TestHTMLStyleSheet(t *testing.T) {
+	assert := require.New(t)
+	css := HTMLStyleSheet()
+	assert.True(strings.HasPrefix(css, "pre.lcf { background-color: #000000; color: #e5e5e5; }\n"))
+	assert.Contains(css, ".lcf-bold { font-weight: bold; }\n")
+	assert.Contains(css, ".lcf-red { color: #cd0000; }\n")
+	assert.Contains(css, ".lcf-bg-hiwhite { background-color: #ffffff; }\n")
+}
@@ -0,0 +1,112 @@
+package lcf
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelStyle is how a log level is shown by the level attributes.
+type LevelStyle struct {
+	// Long name shown by %[levelName]s (e.g. "WARNING").
+	Name string
+
+	// Name shown by %[shortLevelName]s (e.g. "WARN"). Defaults to the first four letters of Name.
+	ShortName string
+
+	// Single letter shown by %[levelCode]s (e.g. "W"). Defaults to the first letter of Name.
+	Code string
+
+	// Color of the level name and field keys. Uses the formatter's Color* field of the level if AnsiReset.
+	Color Style
+}
+
+// LevelStyles maps log levels, including custom numeric levels logrus has no name for, to how they are shown. Empty
+// fields of a LevelStyle are filled in from the defaults.
+type LevelStyles map[logrus.Level]LevelStyle
+
+// defaultLevelStyles caches the names of logrus' levels so they do not need to be computed for every entry.
+var defaultLevelStyles = func() map[logrus.Level]LevelStyle {
+	styles := make(map[logrus.Level]LevelStyle)
+	for _, level := range logrus.AllLevels {
+		styles[level] = newLevelStyle(level)
+	}
+	return styles
+}()
+
+// newLevelStyle returns the default names of a level. Levels logrus has no name for are named after their number
+// (e.g. "LEVEL10").
+func newLevelStyle(level logrus.Level) LevelStyle {
+	var style LevelStyle
+	if name := level.String(); name != "unknown" && name != "" {
+		style.Name = strings.ToUpper(name)
+	} else {
+		number := strconv.FormatUint(uint64(level), 10)
+		style.Name, style.ShortName = "LEVEL"+number, "L"+number
+	}
+	return style.withDefaults()
+}
+
+// withDefaults fills in an empty ShortName and Code from Name. Names shorter than four letters are used as is.
+func (s LevelStyle) withDefaults() LevelStyle {
+	if s.ShortName == "" {
+		s.ShortName = s.Name[:runeOffset([]byte(s.Name), 4)]
+	}
+	if s.Code == "" {
+		_, size := utf8.DecodeRuneInString(s.Name)
+		s.Code = s.Name[:size]
+	}
+	return s
+}
+
+// levelStyle returns the names and color of a level: its LevelStyles entry with empty fields filled in from the
+// defaults.
+func (f *CustomFormatter) levelStyle(level logrus.Level) LevelStyle {
+	style, ok := defaultLevelStyles[level]
+	if !ok {
+		style = newLevelStyle(level)
+	}
+	style.Color = f.levelColor(level)
+	custom, ok := f.LevelStyles[level]
+	if !ok {
+		return style
+	}
+	if custom.Name != "" {
+		custom = custom.withDefaults()
+	}
+	if custom.Name != "" {
+		style.Name = custom.Name
+	}
+	if custom.ShortName != "" {
+		style.ShortName = custom.ShortName
+	}
+	if custom.Code != "" {
+		style.Code = custom.Code
+	}
+	return style
+}
+
+// levelColor returns the color of a log level: its LevelStyles color or the Color* field of the level. Default is info.
+func (f *CustomFormatter) levelColor(level logrus.Level) Style {
+	if custom, ok := f.LevelStyles[level]; ok && custom.Color != AnsiReset {
+		return custom.Color
+	}
+	switch level {
+	case logrus.TraceLevel:
+		return f.ColorTrace
+	case logrus.DebugLevel:
+		return f.ColorDebug
+	case logrus.WarnLevel:
+		return f.ColorWarn
+	case logrus.ErrorLevel:
+		return f.ColorError
+	case logrus.PanicLevel:
+		return f.ColorPanic
+	case logrus.FatalLevel:
+		return f.ColorFatal
+	default:
+		return f.ColorInfo
+	}
+}
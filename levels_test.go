@@ -0,0 +1,78 @@
+package lcf
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelStyle(t *testing.T) {
+	formatter := NewFormatter("", nil)
+	formatter.LevelStyles = LevelStyles{
+		logrus.Level(10):  {Name: "NOTICE", Color: AnsiBlue},
+		logrus.Level(11):  {Name: "OK"},
+		logrus.Level(12):  {Name: "ÉTAPE", Code: "*"},
+		logrus.InfoLevel:  {ShortName: "INF"},
+		logrus.ErrorLevel: {Name: "FAIL", Color: Style(AnsiHiRed).Bold()},
+	}
+
+	testCases := []struct {
+		level    logrus.Level
+		expected LevelStyle
+	}{
+		{logrus.TraceLevel, LevelStyle{"TRACE", "TRAC", "T", AnsiHiBlack}},
+		{logrus.DebugLevel, LevelStyle{"DEBUG", "DEBU", "D", AnsiCyan}},
+		{logrus.InfoLevel, LevelStyle{"INFO", "INF", "I", AnsiGreen}},
+		{logrus.WarnLevel, LevelStyle{"WARNING", "WARN", "W", AnsiYellow}},
+		{logrus.ErrorLevel, LevelStyle{"FAIL", "FAIL", "F", Style(AnsiHiRed).Bold()}},
+		{logrus.FatalLevel, LevelStyle{"FATAL", "FATA", "F", AnsiMagenta}},
+		{logrus.PanicLevel, LevelStyle{"PANIC", "PANI", "P", AnsiMagenta}},
+		{logrus.Level(10), LevelStyle{"NOTICE", "NOTI", "N", AnsiBlue}},
+		{logrus.Level(11), LevelStyle{"OK", "OK", "O", AnsiGreen}},
+		{logrus.Level(12), LevelStyle{"ÉTAPE", "ÉTAP", "*", AnsiGreen}},
+		{logrus.Level(13), LevelStyle{"LEVEL13", "L13", "L", AnsiGreen}},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprint(uint32(tc.level)), func(t *testing.T) {
+			assert := require.New(t)
+			assert.Equal(tc.expected, formatter.levelStyle(tc.level))
+		})
+	}
+}
+
+func TestLevelStyles_Format(t *testing.T) {
+	testCases := []struct {
+		level    logrus.Level
+		expected string
+	}{
+		{logrus.TraceLevel, "\033[90mTRACE\033[0m|\033[90mTRAC\033[0m|\033[90mT\033[0m"},
+		{logrus.WarnLevel, "\033[33mWARNING\033[0m|\033[33mWARN\033[0m|\033[33mW\033[0m"},
+		{logrus.Level(10), "\033[34mNOTICE\033[0m|\033[34mNOTI\033[0m|\033[34mN\033[0m"},
+		{logrus.Level(11), "\033[32mOK\033[0m   |\033[32mOK\033[0m|\033[32mO\033[0m"},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprint(uint32(tc.level)), func(t *testing.T) {
+			assert := require.New(t)
+			formatter := NewFormatter("%-5[levelName]s|%[shortLevelName]s|%[levelCode]s", nil)
+			formatter.ForceColors = true
+			formatter.LevelStyles = LevelStyles{
+				logrus.Level(10): {Name: "NOTICE", Color: AnsiBlue},
+				logrus.Level(11): {Name: "OK"},
+			}
+			entry := newBenchmarkEntry(formatter)
+			entry.Level = tc.level
+
+			actual, err := formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal(tc.expected, string(actual))
+
+			// Handlers are used with fmt verbs other than %s.
+			formatter.ParseTemplate("%-5[levelName]v|%[shortLevelName]v|%[levelCode]v", nil)
+			actual, err = formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal(tc.expected, string(actual))
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package lcf
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// itemType identifies the type of lexical items produced by the template lexer.
+type itemType int
+
+const (
+	itemError        itemType = iota // Error occurred. Value is the description of the problem.
+	itemText                         // Plain text between directives.
+	itemPercent                      // Escaped percent sign ("%%").
+	itemDirective                    // Percent sign starting a directive.
+	itemGroupStart                   // Start of a conditional group ("%{").
+	itemGroupEnd                     // End of a conditional group ("%}").
+	itemFlags                        // Flags after the percent sign (e.g. "-").
+	itemWidth                        // Minimum width (e.g. "20").
+	itemPrecision                    // Precision including the leading dot (e.g. ".4").
+	itemLeftBracket                  // Left bracket before the attribute name.
+	itemName                         // Attribute name (e.g. "levelName").
+	itemOption                       // Option after a pipe (e.g. "upper" in "%[message|upper]s").
+	itemRightBracket                 // Right bracket after the attribute name and options.
+	itemVerb                         // Verb ending a directive (e.g. "s").
+	itemEOF                          // End of the template.
+)
+
+// item is a token returned by the lexer along with its position in the template.
+type item struct {
+	typ itemType
+	pos int
+	val string
+}
+
+// lexer holds the state of the scanner.
+type lexer struct {
+	input     string
+	start     int // Start position of the current item.
+	pos       int // Current position in the input.
+	directive int // Position of the percent sign starting the current directive.
+	items     []item
+}
+
+// stateFn represents the state of the lexer as a function returning the next state.
+type stateFn func(*lexer) stateFn
+
+// lex scans a template string and returns its lexical items, always ending with itemEOF.
+//
+// When a directive is malformed an itemError is emitted and scanning resumes right after the directive's percent sign
+// with that percent sign treated as plain text. The parser decides whether that is fatal.
+func lex(input string) []item {
+	l := &lexer{input: input}
+	for state := lexText; state != nil; {
+		state = state(l)
+	}
+	return l.items
+}
+
+// emit passes the current item to the parser.
+func (l *lexer) emit(t itemType) {
+	l.items = append(l.items, item{t, l.start, l.input[l.start:l.pos]})
+	l.start = l.pos
+}
+
+// errorf emits an error item at pos and rewinds to treat the current directive's percent sign as plain text.
+func (l *lexer) errorf(pos int, msg string) stateFn {
+	l.items = append(l.items, item{itemError, pos, msg})
+	l.start = l.directive
+	l.pos = l.directive + 1
+	return lexText
+}
+
+// acceptRun consumes a run of bytes from the valid set.
+func (l *lexer) acceptRun(valid string) {
+	for l.pos < len(l.input) && strings.IndexByte(valid, l.input[l.pos]) >= 0 {
+		l.pos++
+	}
+}
+
+// lexText scans until the next directive or the end of the input.
+func lexText(l *lexer) stateFn {
+	if i := strings.IndexByte(l.input[l.pos:], '%'); i >= 0 {
+		l.pos += i
+	} else {
+		l.pos = len(l.input)
+	}
+	if l.pos > l.start {
+		l.emit(itemText)
+	}
+	if l.pos == len(l.input) {
+		l.emit(itemEOF)
+		return nil
+	}
+	return lexDirective
+}
+
+// lexDirective scans the percent sign and everything up to the attribute's left bracket.
+func lexDirective(l *lexer) stateFn {
+	l.directive = l.pos
+	l.pos++
+	if l.pos < len(l.input) && l.input[l.pos] == '%' {
+		l.pos++
+		l.emit(itemPercent)
+		return lexText
+	}
+	if l.pos < len(l.input) && (l.input[l.pos] == '{' || l.input[l.pos] == '}') {
+		l.pos++
+		if l.input[l.pos-1] == '{' {
+			l.emit(itemGroupStart)
+		} else {
+			l.emit(itemGroupEnd)
+		}
+		return lexText
+	}
+	l.emit(itemDirective)
+	if l.pos < len(l.input) && l.input[l.pos] == '(' {
+		return lexPythonDirective
+	}
+	lexFormatSpec(l)
+
+	// Every directive must name an attribute.
+	if l.pos >= len(l.input) || l.input[l.pos] != '[' {
+		if l.pos < len(l.input) && l.input[l.pos] == ']' {
+			return l.errorf(l.pos, "unbalanced bracket")
+		}
+		return l.errorf(l.directive, "stray % (use %% for a literal percent sign)")
+	}
+	return lexInsideBrackets
+}
+
+// lexFormatSpec scans flags, width, and precision.
+func lexFormatSpec(l *lexer) {
+	if l.acceptRun(validFlags); l.pos > l.start {
+		l.emit(itemFlags)
+	}
+	if l.acceptRun("0123456789"); l.pos > l.start {
+		l.emit(itemWidth)
+	}
+	if l.pos < len(l.input) && l.input[l.pos] == '.' {
+		l.pos++
+		l.acceptRun("0123456789")
+		l.emit(itemPrecision)
+	}
+}
+
+// lexPythonDirective scans the rest of a Python logging style directive (e.g. "(levelname)-8s" in "%(levelname)-8s").
+// Parentheses are emitted as brackets.
+func lexPythonDirective(l *lexer) stateFn {
+	open := l.pos
+	end := strings.IndexAny(l.input[open+1:], ")%(\n")
+	if end < 0 || l.input[open+1+end] != ')' {
+		return l.errorf(open, "unbalanced parenthesis")
+	}
+	end += open + 1
+	l.pos++
+	l.emit(itemLeftBracket)
+	l.pos = end
+	if l.pos == l.start {
+		return l.errorf(open, "empty attribute name")
+	}
+	l.emit(itemName)
+	l.pos++
+	l.emit(itemRightBracket)
+	lexFormatSpec(l)
+
+	// Python ignores length modifiers.
+	if l.pos < len(l.input) && strings.IndexByte("hlL", l.input[l.pos]) >= 0 {
+		l.pos++
+		l.start = l.pos
+	}
+
+	if l.pos >= len(l.input) {
+		return l.errorf(l.pos, "missing conversion type after attribute")
+	}
+	verb, width := utf8.DecodeRuneInString(l.input[l.pos:])
+	if !strings.ContainsRune(pythonTypes, verb) {
+		return l.errorf(l.pos, "unsupported conversion type "+strconv.QuoteRune(verb)+" after attribute")
+	}
+	l.pos += width
+	l.emit(itemVerb)
+	return lexText
+}
+
+// lexInsideBrackets scans the attribute name and its options up to and including the right bracket.
+func lexInsideBrackets(l *lexer) stateFn {
+	open := l.pos
+	end := strings.IndexAny(l.input[open+1:], "]%[\n")
+	if end < 0 || l.input[open+1+end] != ']' {
+		return l.errorf(open, "unbalanced bracket")
+	}
+	end += open + 1
+	l.pos++
+	l.emit(itemLeftBracket)
+
+	// Attribute name.
+	if i := strings.IndexByte(l.input[l.pos:end], '|'); i >= 0 {
+		l.pos += i
+	} else {
+		l.pos = end
+	}
+	if l.pos == l.start {
+		return l.errorf(open, "empty attribute name")
+	}
+	l.emit(itemName)
+
+	// Options.
+	for l.pos < end {
+		l.pos++ // Skip pipe.
+		l.start = l.pos
+		if i := strings.IndexByte(l.input[l.pos:end], '|'); i >= 0 {
+			l.pos += i
+		} else {
+			l.pos = end
+		}
+		l.emit(itemOption)
+	}
+
+	l.pos++
+	l.emit(itemRightBracket)
+	return lexVerb
+}
+
+// lexVerb scans the verb ending the directive.
+func lexVerb(l *lexer) stateFn {
+	if l.pos >= len(l.input) {
+		return l.errorf(l.pos, "missing verb after attribute")
+	}
+	verb, width := utf8.DecodeRuneInString(l.input[l.pos:])
+	if !strings.ContainsRune(validVerbs, verb) {
+		return l.errorf(l.pos, "unsupported verb "+strconv.QuoteRune(verb)+" after attribute")
+	}
+	l.pos += width
+	l.emit(itemVerb)
+	return lexText
+}
@@ -0,0 +1,100 @@
+package lcf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLex(t *testing.T) {
+	testCases := []struct {
+		template string
+		expected []item
+	}{
+		{"", []item{{itemEOF, 0, ""}}},
+		{"text", []item{{itemText, 0, "text"}, {itemEOF, 4, ""}}},
+		{"%-07.4[levelName]s\n", []item{
+			{itemDirective, 0, "%"},
+			{itemFlags, 1, "-0"},
+			{itemWidth, 3, "7"},
+			{itemPrecision, 4, ".4"},
+			{itemLeftBracket, 6, "["},
+			{itemName, 7, "levelName"},
+			{itemRightBracket, 16, "]"},
+			{itemVerb, 17, "s"},
+			{itemText, 18, "\n"},
+			{itemEOF, 19, ""},
+		}},
+		{"%%[message|upper|trunc:5]s", []item{
+			{itemPercent, 0, "%%"},
+			{itemText, 2, "[message|upper|trunc:5]s"},
+			{itemEOF, 26, ""},
+		}},
+		{"%[message|upper|]s", []item{
+			{itemDirective, 0, "%"},
+			{itemLeftBracket, 1, "["},
+			{itemName, 2, "message"},
+			{itemOption, 10, "upper"},
+			{itemOption, 16, ""},
+			{itemRightBracket, 16, "]"},
+			{itemVerb, 17, "s"},
+			{itemEOF, 18, ""},
+		}},
+		{"1% %[a]s", []item{
+			{itemText, 0, "1"},
+			{itemDirective, 1, "%"},
+			{itemFlags, 2, " "},
+			{itemError, 1, "stray % (use %% for a literal percent sign)"},
+			{itemText, 1, "% "},
+			{itemDirective, 3, "%"},
+			{itemLeftBracket, 4, "["},
+			{itemName, 5, "a"},
+			{itemRightBracket, 6, "]"},
+			{itemVerb, 7, "s"},
+			{itemEOF, 8, ""},
+		}},
+		{"%(levelname)-8ls", []item{
+			{itemDirective, 0, "%"},
+			{itemLeftBracket, 1, "("},
+			{itemName, 2, "levelname"},
+			{itemRightBracket, 11, ")"},
+			{itemFlags, 12, "-"},
+			{itemWidth, 13, "8"},
+			{itemVerb, 15, "s"},
+			{itemEOF, 16, ""},
+		}},
+		{"%(a]s", []item{
+			{itemDirective, 0, "%"},
+			{itemError, 1, "unbalanced parenthesis"},
+			{itemText, 0, "%(a]s"},
+			{itemEOF, 5, ""},
+		}},
+		{"%{[%[a]s]%}%%}", []item{
+			{itemGroupStart, 0, "%{"},
+			{itemText, 2, "["},
+			{itemDirective, 3, "%"},
+			{itemLeftBracket, 4, "["},
+			{itemName, 5, "a"},
+			{itemRightBracket, 6, "]"},
+			{itemVerb, 7, "s"},
+			{itemText, 8, "]"},
+			{itemGroupEnd, 9, "%}"},
+			{itemPercent, 11, "%%"},
+			{itemText, 13, "}"},
+			{itemEOF, 14, ""},
+		}},
+		{"%[a", []item{
+			{itemDirective, 0, "%"},
+			{itemError, 1, "unbalanced bracket"},
+			{itemText, 0, "%[a"},
+			{itemEOF, 3, ""},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			assert.Equal(tc.expected, lex(tc.template))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package lcf
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldQuoting selects how %[fields]s quotes keys and values.
+type FieldQuoting int
+
+const (
+	// LogfmtQuoting writes logfmt (https://brandur.org/logfmt) that log shippers can parse back. Empty values and values
+	// with spaces, "=", quotes, control characters or invalid UTF-8 are quoted, escaping quotes, backslashes and control
+	// characters with backslashes. Characters not allowed in keys are replaced with underscores.
+	LogfmtQuoting FieldQuoting = iota
+
+	// TextFormatterQuoting quotes like logrus' TextFormatter: values with characters other than letters, digits and
+	// "-._/@^+" are quoted with Go syntax (strconv.Quote). Empty values and keys are written as is.
+	TextFormatterQuoting
+)
+
+// key returns a key of %[fields]s as it is written.
+func (q FieldQuoting) key(key string) string {
+	if q == TextFormatterQuoting || key != "" && strings.IndexFunc(key, isLogfmtSpecial) < 0 {
+		return key
+	}
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if isLogfmtSpecial(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// quoteValue quotes the value of %[fields]s written to the render buffer since start if it needs quoting.
+func (q FieldQuoting) quoteValue(s *renderState, start int) {
+	value := s.buffer.Bytes()[start:]
+	switch {
+	case q == TextFormatterQuoting && needsTextFormatterQuoting(value):
+		s.scratch = strconv.AppendQuote(s.scratch[:0], string(value))
+	case q != TextFormatterQuoting && needsLogfmtQuoting(value):
+		s.scratch = appendLogfmtQuoted(s.scratch[:0], value)
+	default:
+		return
+	}
+	s.buffer.Truncate(start)
+	s.buffer.Write(s.scratch)
+}
+
+// isLogfmtSpecial returns true if a rune cannot be part of an unquoted logfmt key or value.
+func isLogfmtSpecial(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7F || r == utf8.RuneError
+}
+
+// needsLogfmtQuoting returns true if a logfmt value is empty or has characters that must be quoted.
+func needsLogfmtQuoting(value []byte) bool {
+	if len(value) == 0 {
+		return true
+	}
+	for i := 0; i < len(value); {
+		if value[i] < utf8.RuneSelf {
+			if isLogfmtSpecial(rune(value[i])) {
+				return true
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(value[i:])
+		if r == utf8.RuneError {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+// appendLogfmtQuoted appends a quoted logfmt value. Quotes, backslashes and control characters are escaped like in
+// JSON strings and invalid UTF-8 is replaced with U+FFFD.
+func appendLogfmtQuoted(b, value []byte) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRune(value[i:])
+		switch {
+		case r == '"' || r == '\\':
+			b = append(b, '\\', byte(r))
+		case r == '\n':
+			b = append(b, '\\', 'n')
+		case r == '\r':
+			b = append(b, '\\', 'r')
+		case r == '\t':
+			b = append(b, '\\', 't')
+		case r < ' ' || r == 0x7F:
+			b = append(b, '\\', 'u', '0', '0', hex[r>>4], hex[r&0xF])
+		case r == utf8.RuneError && size == 1:
+			b = append(b, "�"...)
+		default:
+			b = append(b, value[i:i+size]...)
+		}
+		i += size
+	}
+	return append(b, '"')
+}
+
+// needsTextFormatterQuoting returns true if logrus' TextFormatter quotes a value.
+func needsTextFormatterQuoting(value []byte) bool {
+	for _, c := range value {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-._/@^+", c) >= 0) {
+			return true
+		}
+	}
+	return false
+}
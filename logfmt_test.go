@@ -0,0 +1,89 @@
+package lcf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldQuoting(t *testing.T) {
+	testCases := []struct {
+		key           string
+		value         interface{}
+		logfmt        string
+		textFormatter string
+	}{
+		{"a", "b", "a=b", "a=b"},
+		{"n", -1.5, "n=-1.5", "n=-1.5"},
+		{"ok", true, "ok=true", "ok=true"},
+		{"path", "/var/log/app.log", "path=/var/log/app.log", "path=/var/log/app.log"},
+		{"url", "https://example.com/?q=1", `url="https://example.com/?q=1"`, `url="https://example.com/?q=1"`},
+		{"msg", "two words", `msg="two words"`, `msg="two words"`},
+		{"empty", "", `empty=""`, "empty="},
+		{"quote", `say "hi"`, `quote="say \"hi\""`, `quote="say \"hi\""`},
+		{"slash", `C:\dir`, `slash=C:\dir`, `slash="C:\\dir"`},
+		{"slash2", `C:\my dir`, `slash2="C:\\my dir"`, `slash2="C:\\my dir"`},
+		{"lines", "a\nb\tc\r\x01\x7f", `lines="a\nb\tc\r\u0001\u007f"`, `lines="a\nb\tc\r\x01\x7f"`},
+		{"utf8", "日本", "utf8=日本", `utf8="日本"`},
+		{"bad", "a\xffb", `bad="a�b"`, `bad="a\xffb"`},
+		{"err", errors.New("file not found"), `err="file not found"`, `err="file not found"`},
+		{"my key", "v", "my_key=v", "my key=v"},
+		{"k=\"v\"", "v", "k__v_=v", "k=\"v\"=v"},
+	}
+
+	for _, tc := range testCases {
+		for _, quoting := range []FieldQuoting{LogfmtQuoting, TextFormatterQuoting} {
+			t.Run(fmt.Sprintf("%s %d", tc.key, quoting), func(t *testing.T) {
+				assert := require.New(t)
+				formatter := NewFormatter("%[fields]s", nil)
+				formatter.DisableColors = true
+				formatter.FieldQuoting = quoting
+				entry := logrus.NewEntry(logrus.New())
+				entry.Data[tc.key] = tc.value
+
+				actual, err := formatter.Format(entry)
+				assert.NoError(err)
+				expected := tc.logfmt
+				if quoting == TextFormatterQuoting {
+					expected = tc.textFormatter
+				}
+				assert.Equal(" "+expected, string(actual))
+			})
+		}
+	}
+}
+
+func TestFieldQuoting_Colors(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%[fields]s", nil)
+	formatter.ForceColors = true
+	formatter.ColorFieldValue = AnsiCyan
+	entry := newBenchmarkEntry(formatter)
+	entry.Data = logrus.Fields{"a b": "c d", "e": ""}
+
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal(" \033[33ma_b\033[0m=\033[36m\"c d\"\033[0m \033[33me\033[0m=\033[36m\"\"\033[0m", string(actual))
+}
+
+func TestTextFormatterQuoting(t *testing.T) {
+	// Values are quoted exactly like logrus' TextFormatter does.
+	assert := require.New(t)
+	values := []interface{}{"abc", "a b", "", "x=y", `"q"`, 10, -3.25, nil, errors.New("oops: bad"), "ü", "+-._/@^"}
+	textFormatter := &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true}
+	formatter := NewFormatter("%[fields]s", nil)
+	formatter.DisableColors = true
+	formatter.FieldQuoting = TextFormatterQuoting
+	for _, value := range values {
+		entry := logrus.NewEntry(logrus.New())
+		entry.Data["k"] = value
+		expected, err := textFormatter.Format(entry)
+		assert.NoError(err)
+		actual, err := formatter.Format(entry)
+		assert.NoError(err)
+		assert.Equal(string(expected), `level=panic`+string(actual)+"\n", "value %#v", value)
+	}
+}
@@ -0,0 +1,299 @@
+package lcf
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Style is a foreground color, an optional background color and text attributes (e.g. bold) written as one ANSI SGR
+// sequence. The Ansi* constants are foreground styles so existing code like "formatter.ColorWarn = AnsiYellow" keeps
+// working. The zero value (AnsiReset) means no color.
+//
+// Colors from the 256 color palette (Color256) and 24-bit colors (RGB) are downgraded to the closest color the
+// formatter's Palette supports.
+type Style uint64
+
+// Layout of a Style. Colors are a 24 bit value and a 2 bit kind. Basic colors store their foreground SGR code (e.g. 31
+// for red) as the value, also when used as the background.
+const (
+	styleValueBits  = 24
+	styleValueMask  = 1<<styleValueBits - 1
+	styleKindMask   = 3
+	styleColorBits  = styleValueBits + 2
+	styleColorMask  = 1<<styleColorBits - 1
+	styleBackground = styleColorBits // Shift of the background color.
+	styleAttributes = 2 * styleColorBits
+
+	colorBasic = 0
+	color256   = 1
+	colorRGB   = 2
+)
+
+// Text attributes.
+const (
+	StyleBold Style = 1 << (styleAttributes + iota)
+	StyleDim
+	StyleItalic
+	StyleUnderline
+)
+
+// SGR codes and names of the text attributes in the same order as the Style* constants.
+var (
+	attributeCodes = [...]int{1, 2, 3, 4}
+	attributeWords = [...]string{"bold", "dim", "italic", "underline"}
+)
+
+// Color256 returns the foreground style of a color from the 256 color palette.
+//
+// :param index: Index of the color in the palette (e.g. 208 for orange).
+func Color256(index uint8) Style {
+	return Style(color256<<styleValueBits | int(index))
+}
+
+// RGB returns the foreground style of a 24-bit color.
+//
+// :param r: Red.
+//
+// :param g: Green.
+//
+// :param b: Blue.
+func RGB(r, g, b uint8) Style {
+	return Style(colorRGB<<styleValueBits | int(r)<<16 | int(g)<<8 | int(b))
+}
+
+// Bold returns the style with the bold attribute added.
+func (s Style) Bold() Style { return s | StyleBold }
+
+// Dim returns the style with the dim attribute added.
+func (s Style) Dim() Style { return s | StyleDim }
+
+// Italic returns the style with the italic attribute added.
+func (s Style) Italic() Style { return s | StyleItalic }
+
+// Underline returns the style with the underline attribute added.
+func (s Style) Underline() Style { return s | StyleUnderline }
+
+// On returns the style with the foreground color of background as its background color (e.g. AnsiWhite.On(AnsiRed)
+// for white text on red).
+func (s Style) On(background Style) Style {
+	return s&^(styleColorMask<<styleBackground) | background&styleColorMask<<styleBackground
+}
+
+// Names of the 16 basic colors in lower case, indexed like the 256 color palette.
+var basicColorNames = [16]string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+	"hiblack", "hired", "higreen", "hiyellow", "hiblue", "himagenta", "hicyan", "hiwhite",
+}
+
+// Colors by name, as used in ParseColor.
+var colorNames = func() map[string]Style {
+	names := make(map[string]Style, len(basicColorNames))
+	for index, name := range basicColorNames {
+		names[name] = Style(basicCode(index))
+	}
+	return names
+}()
+
+// Text attributes by name, as used in ParseColor.
+var attributeNames = func() map[string]Style {
+	names := make(map[string]Style, len(attributeWords))
+	for i, name := range attributeWords {
+		names[name] = StyleBold << uint(i)
+	}
+	return names
+}()
+
+// ParseColor parses a style written as words (e.g. "bold yellow", "#ff8700 on black" or "underline 208"). Colors are
+// names of the Ansi* constants (e.g. "red" or "hiRed"), 256 color palette indexes or "#RRGGBB" hex colors. A color
+// after "on" is the background color. Names are case insensitive.
+//
+// :param text: Style to parse. Empty or "none" is no style.
+func ParseColor(text string) (Style, error) {
+	var style Style
+	background := false
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if word == "on" {
+			background = true
+			continue
+		}
+		if attribute, ok := attributeNames[word]; ok {
+			style |= attribute
+			continue
+		}
+		color, err := parseColorWord(word)
+		if err != nil {
+			return 0, err
+		}
+		if background {
+			style = style.On(color)
+			background = false
+		} else {
+			style = style&^styleColorMask | color
+		}
+	}
+	if background {
+		return 0, fmt.Errorf("lcf: missing background color in %q", text)
+	}
+	return style, nil
+}
+
+// parseColorWord parses one color of ParseColor.
+func parseColorWord(word string) (Style, error) {
+	if color, ok := colorNames[word]; ok || word == "none" {
+		return color, nil
+	}
+	if strings.HasPrefix(word, "#") && len(word) == 7 {
+		if rgb, err := strconv.ParseUint(word[1:], 16, 32); err == nil {
+			return RGB(uint8(rgb>>16), uint8(rgb>>8), uint8(rgb)), nil
+		}
+	}
+	if index, err := strconv.ParseUint(word, 10, 8); err == nil {
+		return Color256(uint8(index)), nil
+	}
+	return 0, fmt.Errorf("lcf: unknown color %q", word)
+}
+
+// Palette is the set of colors a terminal supports.
+type Palette int
+
+const (
+	// Palette16 terminals support the 16 colors of the Ansi* constants.
+	Palette16 Palette = iota
+
+	// Palette256 terminals support the 256 color palette (e.g. TERM=xterm-256color).
+	Palette256
+
+	// PaletteTrueColor terminals support 24-bit colors (e.g. COLORTERM=truecolor).
+	PaletteTrueColor
+)
+
+// detectPalette returns the palette advertised by the terminal's environment variables.
+func detectPalette() Palette {
+	switch colorTerm := os.Getenv("COLORTERM"); {
+	case colorTerm == "truecolor" || colorTerm == "24bit" || os.Getenv("WT_SESSION") != "":
+		return PaletteTrueColor
+	case strings.Contains(os.Getenv("TERM"), "256color"):
+		return Palette256
+	}
+	return Palette16
+}
+
+// appendSGR appends the ANSI SGR sequence of a style (e.g. "\033[1;31m") to b, downgrading colors to the palette.
+func appendSGR(b []byte, style Style, palette Palette) []byte {
+	b = append(b, "\033["...)
+	start := len(b)
+	for i, code := range attributeCodes {
+		if style&(StyleBold<<uint(i)) != 0 {
+			b = appendParam(b, start, code)
+		}
+	}
+	b = appendSGRColor(b, start, style&styleColorMask, palette, 0)
+	b = appendSGRColor(b, start, style>>styleBackground&styleColorMask, palette, 10)
+	return append(b, 'm')
+}
+
+// appendSGRColor appends the SGR parameters of a color. offset is 0 for foreground and 10 for background colors.
+func appendSGRColor(b []byte, start int, color Style, palette Palette, offset int) []byte {
+	value := int(color & styleValueMask)
+	switch kind := int(color >> styleValueBits & styleKindMask); {
+	case kind == colorRGB && palette == PaletteTrueColor:
+		b = appendParam(b, start, 38+offset)
+		b = appendParam(b, start, 2)
+		b = appendParam(b, start, value>>16)
+		b = appendParam(b, start, value>>8&0xFF)
+		return appendParam(b, start, value&0xFF)
+	case kind == colorRGB && palette == Palette256:
+		value, kind = rgbTo256(value), color256
+		fallthrough
+	case kind == color256 && palette != Palette16:
+		b = appendParam(b, start, 38+offset)
+		b = appendParam(b, start, 5)
+		return appendParam(b, start, value)
+	case kind == color256:
+		return appendParam(b, start, nearestBasic(paletteRGB(value))+offset)
+	case kind == colorRGB:
+		return appendParam(b, start, nearestBasic(value)+offset)
+	case value != 0:
+		return appendParam(b, start, value+offset)
+	}
+	return b
+}
+
+// appendParam appends one SGR parameter, separated by a semicolon from previous ones written since start.
+func appendParam(b []byte, start, code int) []byte {
+	if len(b) > start {
+		b = append(b, ';')
+	}
+	return strconv.AppendInt(b, int64(code), 10)
+}
+
+// RGB values of the 16 basic colors as shown by xterm, indexed like the 256 color palette.
+var basicRGB = [16]int{
+	0x000000, 0xCD0000, 0x00CD00, 0xCDCD00, 0x0000EE, 0xCD00CD, 0x00CDCD, 0xE5E5E5,
+	0x7F7F7F, 0xFF0000, 0x00FF00, 0xFFFF00, 0x5C5CFF, 0xFF00FF, 0x00FFFF, 0xFFFFFF,
+}
+
+// Intensities of the 6x6x6 color cube of the 256 color palette.
+var cubeLevels = [6]int{0, 0x5F, 0x87, 0xAF, 0xD7, 0xFF}
+
+// paletteRGB returns the RGB value of a 256 color palette index.
+func paletteRGB(index int) int {
+	switch {
+	case index < 16:
+		return basicRGB[index]
+	case index < 232:
+		index -= 16
+		return cubeLevels[index/36]<<16 | cubeLevels[index/6%6]<<8 | cubeLevels[index%6]
+	}
+	gray := 8 + (index-232)*10
+	return gray<<16 | gray<<8 | gray
+}
+
+// rgbTo256 returns the index of the closest color in the 256 color palette, excluding the 16 basic colors whose
+// actual values vary between terminals.
+func rgbTo256(rgb int) int {
+	best, bestDistance := 16, -1
+	for index := 16; index < 256; index++ {
+		if distance := rgbDistance(rgb, paletteRGB(index)); bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = index, distance
+		}
+	}
+	return best
+}
+
+// nearestBasic returns the foreground SGR code (e.g. 31) of the basic color closest to an RGB value.
+func nearestBasic(rgb int) int {
+	best, bestDistance := 0, -1
+	for index, basic := range basicRGB {
+		if distance := rgbDistance(rgb, basic); bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = index, distance
+		}
+	}
+	return basicCode(best)
+}
+
+// basicCode returns the foreground SGR code (e.g. 31) of a basic color's 256 color palette index.
+func basicCode(index int) int {
+	if index < 8 {
+		return 30 + index
+	}
+	return 90 + index - 8
+}
+
+// basicIndex returns the 256 color palette index of a basic color's foreground SGR code (e.g. 1 for 31).
+func basicIndex(code int) int {
+	if code >= 90 {
+		return code - 90 + 8
+	}
+	return code - 30
+}
+
+// rgbDistance returns the squared distance between two RGB values.
+func rgbDistance(a, b int) int {
+	dr := a>>16 - b>>16
+	dg := a>>8&0xFF - b>>8&0xFF
+	db := a&0xFF - b&0xFF
+	return dr*dr + dg*dg + db*db
+}
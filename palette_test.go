@@ -0,0 +1,135 @@
+package lcf
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendSGR(t *testing.T) {
+	testCases := []struct {
+		style    Style
+		palette  Palette
+		expected string
+	}{
+		{AnsiRed, Palette16, "\033[31m"},
+		{AnsiHiCyan, PaletteTrueColor, "\033[96m"},
+		{Style(AnsiRed).Bold(), Palette16, "\033[1;31m"},
+		{Style(AnsiWhite).On(AnsiRed).Underline(), Palette16, "\033[4;37;41m"},
+		{Style(AnsiWhite).On(AnsiHiBlack), Palette16, "\033[37;100m"},
+		{StyleItalic | StyleDim, Palette16, "\033[2;3m"},
+		{Color256(208), Palette256, "\033[38;5;208m"},
+		{Color256(208), PaletteTrueColor, "\033[38;5;208m"},
+		{Color256(208), Palette16, "\033[33m"},
+		{Color256(1), Palette16, "\033[31m"},
+		{Color256(0).On(Color256(15)), Palette256, "\033[38;5;0;48;5;15m"},
+		{RGB(255, 135, 0), PaletteTrueColor, "\033[38;2;255;135;0m"},
+		{RGB(255, 135, 0), Palette256, "\033[38;5;208m"},
+		{RGB(255, 135, 0).On(RGB(0, 0, 0)), Palette16, "\033[33;40m"},
+		{RGB(30, 30, 30), Palette256, "\033[38;5;234m"},
+		{Style(AnsiReset).On(RGB(0, 0, 200)), PaletteTrueColor, "\033[48;2;0;0;200m"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%x %d", uint64(tc.style), tc.palette), func(t *testing.T) {
+			assert := require.New(t)
+			assert.Equal(tc.expected, string(appendSGR(nil, tc.style, tc.palette)))
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected Style
+	}{
+		{"", AnsiReset},
+		{"none", AnsiReset},
+		{"red", AnsiRed},
+		{"Bold HiRed", Style(AnsiHiRed).Bold()},
+		{"208", Color256(208)},
+		{"#FF8700 on black", RGB(255, 135, 0).On(AnsiBlack)},
+		{"underline italic dim on 236", Style(0).On(Color256(236)).Underline().Italic().Dim()},
+		{"red blue", AnsiBlue},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			assert := require.New(t)
+			style, err := ParseColor(tc.text)
+			assert.NoError(err)
+			assert.Equal(tc.expected, style)
+		})
+	}
+
+	assert := require.New(t)
+	_, err := ParseColor("bold purple")
+	assert.EqualError(err, `lcf: unknown color "purple"`)
+	_, err = ParseColor("#12345")
+	assert.EqualError(err, `lcf: unknown color "#12345"`)
+	_, err = ParseColor("256")
+	assert.EqualError(err, `lcf: unknown color "256"`)
+	_, err = ParseColor("red on")
+	assert.EqualError(err, `lcf: missing background color in "red on"`)
+}
+
+func TestDetectPalette(t *testing.T) {
+	testCases := []struct {
+		colorTerm, term, wtSession string
+		expected                   Palette
+	}{
+		{"", "xterm", "", Palette16},
+		{"", "xterm-256color", "", Palette256},
+		{"truecolor", "xterm-256color", "", PaletteTrueColor},
+		{"24bit", "", "", PaletteTrueColor},
+		{"", "", "1", PaletteTrueColor},
+		{"yes", "screen-256color", "", Palette256},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q %q %q", tc.colorTerm, tc.term, tc.wtSession), func(t *testing.T) {
+			assert := require.New(t)
+			defer setenv("COLORTERM", tc.colorTerm)()
+			defer setenv("TERM", tc.term)()
+			defer setenv("WT_SESSION", tc.wtSession)()
+			assert.Equal(tc.expected, detectPalette())
+		})
+	}
+}
+
+func TestCustomFormatter_Style(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%-9[levelName]s|%[fields]s", nil)
+	formatter.ForceColors = true
+	formatter.Palette = Palette256
+	formatter.ColorWarn = RGB(255, 135, 0).Bold()
+	entry := newBenchmarkEntry(formatter)
+	delete(entry.Data, "c")
+
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[1;38;5;208mWARNING\033[0m  | \033[1;38;5;208ma\033[0m=b \033[1;38;5;208mname\033[0m=LogMsgs",
+		string(actual))
+	assert.Equal("\033[1;38;5;208mTest\033[0m", Color(entry, formatter, "Test"))
+
+	entry.Level = logrus.InfoLevel
+	assert.Equal("\033[32mTest\033[0m", Color(entry, formatter, "Test"))
+}
+
+// setenv sets or unsets (if value is empty) an environment variable and returns a function restoring it.
+func setenv(key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
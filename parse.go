@@ -0,0 +1,480 @@
+package lcf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Verbs accepted after an attribute (e.g. the "s" in "%[message]s"). These are the verbs understood by fmt.Sprintf().
+const validVerbs = "bcdeEfFgGoOpqstTUvxX"
+
+// Verbs formatting strings. Filtered attributes with other verbs are formatted with %s.
+const stringVerbs = "qsvxX"
+
+// Flags accepted between % and the attribute (e.g. the "-" in "%-20[name]s").
+const validFlags = "+-# 0"
+
+// ParseError describes a problem found while strictly parsing a template string. It points to where in the template
+// the problem was found so it can be shown to the user.
+type ParseError struct {
+	// The template string being parsed.
+	Template string
+
+	// Attribute name involved in the problem. Empty if the problem is not about a specific attribute.
+	Attribute string
+
+	// Byte offset in Template where the problem was found.
+	Offset int
+
+	// Line number (starting at 1) where the problem was found.
+	Line int
+
+	// Column (starting at 1, counted in runes) where the problem was found.
+	Column int
+
+	// Description of the problem (e.g. "unknown attribute").
+	Msg string
+}
+
+func newParseError(template string, offset int, attribute, msg string) *ParseError {
+	line := strings.Count(template[:offset], "\n") + 1
+	lineStart := strings.LastIndex(template[:offset], "\n") + 1
+	column := utf8.RuneCountInString(template[lineStart:offset]) + 1
+	return &ParseError{
+		Template:  template,
+		Attribute: attribute,
+		Offset:    offset,
+		Line:      line,
+		Column:    column,
+		Msg:       msg,
+	}
+}
+
+// Error returns the description of the problem with its position and a snippet of the template.
+func (e *ParseError) Error() string {
+	msg := e.Msg
+	if e.Attribute != "" {
+		msg = fmt.Sprintf("%s %q", msg, e.Attribute)
+	}
+	return fmt.Sprintf("lcf: %s at line %d column %d (offset %d)\n%s", msg, e.Line, e.Column, e.Offset, e.Snippet())
+}
+
+// Snippet returns the template line where the problem was found followed by a line with a caret pointing to it.
+func (e *ParseError) Snippet() string {
+	lineStart := strings.LastIndex(e.Template[:e.Offset], "\n") + 1
+	lineEnd := strings.Index(e.Template[e.Offset:], "\n")
+	if lineEnd < 0 {
+		lineEnd = len(e.Template)
+	} else {
+		lineEnd += e.Offset
+	}
+
+	// Keep tabs in the caret line so it lines up with the snippet line.
+	caret := []rune(e.Template[lineStart:e.Offset])
+	for i, r := range caret {
+		if r != '\t' {
+			caret[i] = ' '
+		}
+	}
+	return "\t" + e.Template[lineStart:lineEnd] + "\n\t" + string(caret) + "^"
+}
+
+// Node is an element of a parsed template. It is either a *TextNode, an *AttributeNode, or a *GroupNode.
+type Node interface {
+	// Position returns the byte offsets in the template where the node starts and ends.
+	Position() (pos, end int)
+}
+
+// TextNode is plain text written to the output as-is.
+type TextNode struct {
+	Pos, End int
+
+	// Text to output. Escaped percent signs ("%%") are already unescaped.
+	Text string
+}
+
+// Position returns the byte offsets in the template where the node starts and ends.
+func (n *TextNode) Position() (int, int) {
+	return n.Pos, n.End
+}
+
+// GroupNode is a conditional group (e.g. "%{[%[name]s] %}"). It is omitted from the output if it contains attributes
+// and all of them render empty.
+type GroupNode struct {
+	Pos, End int
+
+	// Nodes between "%{" and "%}".
+	Nodes []Node
+}
+
+// Position returns the byte offsets in the template where the node starts and ends.
+func (n *GroupNode) Position() (int, int) {
+	return n.Pos, n.End
+}
+
+// AttributeNode is a directive such as "%-7.4[levelName]s" naming an attribute whose value is formatted into the
+// output.
+type AttributeNode struct {
+	Pos, End int
+
+	// Flags between the percent sign and the width (e.g. "-" or "+#").
+	Flags string
+
+	// Minimum width of the formatted value. -1 if not specified.
+	Width int
+
+	// Precision of the formatted value. -1 if not specified.
+	Precision int
+
+	// Attribute name between the brackets (e.g. "levelName").
+	Name string
+
+	// Filters after the attribute name, separated by pipes (e.g. ["upper", "trunc:5"] in "%[message|upper|trunc:5]s").
+	Options []string
+
+	// Verb ending the directive (e.g. 's').
+	Verb rune
+
+	namePos   int
+	directive string
+}
+
+// Position returns the byte offsets in the template where the node starts and ends.
+func (n *AttributeNode) Position() (int, int) {
+	return n.Pos, n.End
+}
+
+// HasFlag returns true if the flag character (e.g. '-') is present in the directive.
+func (n *AttributeNode) HasFlag(flag byte) bool {
+	return strings.IndexByte(n.Flags, flag) >= 0
+}
+
+// Directive returns the fmt.Sprintf() directive without the attribute (e.g. "%-7.4s").
+func (n *AttributeNode) Directive() string {
+	if n.directive != "" {
+		return n.directive
+	}
+	return n.buildDirective("")
+}
+
+// String returns the directive as written in a template (e.g. "%-7.4[levelName]s").
+func (n *AttributeNode) String() string {
+	brackets := "[" + n.Name
+	for _, option := range n.Options {
+		brackets += "|" + option
+	}
+	return n.buildDirective(brackets + "]")
+}
+
+func (n *AttributeNode) buildDirective(brackets string) string {
+	var buffer bytes.Buffer
+	buffer.WriteByte('%')
+	buffer.WriteString(n.Flags)
+	if n.Width >= 0 {
+		buffer.WriteString(strconv.Itoa(n.Width))
+	}
+	if n.Precision >= 0 {
+		buffer.WriteByte('.')
+		buffer.WriteString(strconv.Itoa(n.Precision))
+	}
+	buffer.WriteString(brackets)
+	buffer.WriteRune(n.Verb)
+	return buffer.String()
+}
+
+// newAttributeNode builds an AttributeNode from the lexical items of one directive.
+func newAttributeNode(items []item) *AttributeNode {
+	node := &AttributeNode{Width: -1, Precision: -1}
+	python := false
+	for _, i := range items {
+		switch i.typ {
+		case itemLeftBracket:
+			python = i.val == "("
+		case itemDirective:
+			node.Pos = i.pos
+		case itemFlags:
+			node.Flags = i.val
+		case itemWidth:
+			node.Width, _ = strconv.Atoi(i.val)
+		case itemPrecision:
+			node.Precision, _ = strconv.Atoi(i.val[1:]) // Like fmt a lone dot means zero.
+		case itemName:
+			node.Name = i.val
+			node.namePos = i.pos
+		case itemOption:
+			node.Options = append(node.Options, i.val)
+		case itemVerb:
+			node.Verb, _ = utf8.DecodeRuneInString(i.val)
+			node.End = i.pos + len(i.val)
+		}
+	}
+	if python {
+		node.Verb = pythonVerb(node.Verb)
+		if name, ok := PythonAttributes[node.Name]; ok {
+			node.Name = name
+		}
+	}
+	node.directive = node.Directive()
+	return node
+}
+
+// parse turns a template string into nodes. If strict is false malformed directives are kept as plain text instead of
+// returning a *ParseError.
+func parse(template string, strict bool) ([]Node, error) {
+	var nodes []Node
+	var pending []item      // Items of the directive being parsed.
+	var groups []*GroupNode // Open groups, innermost last.
+	var outer [][]Node      // Nodes enclosing each open group.
+
+	for _, i := range lex(template) {
+		switch i.typ {
+		case itemError:
+			if strict {
+				var attribute string
+				for _, p := range pending {
+					if p.typ == itemName {
+						attribute = p.val
+					}
+				}
+				return nil, newParseError(template, i.pos, attribute, i.val)
+			}
+			pending = pending[:0]
+		case itemText, itemPercent:
+			text := i.val
+			if i.typ == itemPercent {
+				text = "%"
+			}
+			nodes = appendText(nodes, i.pos, i.pos+len(i.val), text)
+		case itemVerb:
+			nodes = append(nodes, newAttributeNode(append(pending, i)))
+			pending = pending[:0]
+		case itemGroupStart:
+			groups = append(groups, &GroupNode{Pos: i.pos})
+			outer = append(outer, nodes)
+			nodes = nil
+		case itemGroupEnd:
+			if len(groups) == 0 {
+				if strict {
+					return nil, newParseError(template, i.pos, "", "unmatched %} (use %%} for a literal percent sign)")
+				}
+				nodes = appendText(nodes, i.pos, i.pos+len(i.val), i.val)
+				continue
+			}
+			group := groups[len(groups)-1]
+			group.End = i.pos + len(i.val)
+			group.Nodes = nodes
+			nodes = append(outer[len(outer)-1], group)
+			groups, outer = groups[:len(groups)-1], outer[:len(outer)-1]
+		case itemEOF:
+			if len(groups) > 0 && strict {
+				return nil, newParseError(template, groups[len(groups)-1].Pos, "", "unclosed group (missing %})")
+			}
+
+			// Keep unclosed groups' contents as they are with "%{" as plain text.
+			for len(groups) > 0 {
+				group := groups[len(groups)-1]
+				inner := nodes
+				nodes = appendText(outer[len(outer)-1], group.Pos, group.Pos+2, "%{")
+				for _, node := range inner {
+					if text, ok := node.(*TextNode); ok {
+						nodes = appendText(nodes, text.Pos, text.End, text.Text)
+					} else {
+						nodes = append(nodes, node)
+					}
+				}
+				groups, outer = groups[:len(groups)-1], outer[:len(outer)-1]
+			}
+		default:
+			pending = append(pending, i)
+		}
+	}
+	return nodes, nil
+}
+
+// appendText adds text to the nodes, merging it with the previous node if it is text too.
+func appendText(nodes []Node, pos, end int, text string) []Node {
+	if last, ok := lastTextNode(nodes); ok && last.End == pos {
+		last.Text += text
+		last.End = end
+		return nodes
+	}
+	return append(nodes, &TextNode{Pos: pos, End: end, Text: text})
+}
+
+func lastTextNode(nodes []Node) (*TextNode, bool) {
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	node, ok := nodes[len(nodes)-1].(*TextNode)
+	return node, ok
+}
+
+// Parse strictly parses a template string into nodes without resolving attribute names. Malformed directives are
+// reported with a *ParseError.
+//
+// :param template: Pre-processed formatting template (e.g. "%[message]s\n").
+func Parse(template string) ([]Node, error) {
+	return parse(template, true)
+}
+
+// compiledTemplate is a template string resolved to handlers and a render plan.
+type compiledTemplate struct {
+	source       string          // Template string as given by the user.
+	template     string          // Post-processed formatting template (e.g. "%s:%s:%s\n").
+	handlers     []Handler       // Handler functions whose indexes match up with the attributes in the template.
+	attributes   Attributes      // Attribute names used in the template.
+	fieldColumns map[string]bool // Fields shown by their own attribute and omitted from %[fields]s.
+	plan         []step
+	levels       sync.Map // Compiled CustomFormatter.Templates by logrus.Level, cleared by compiling a new template.
+}
+
+// compile resolves attribute nodes to handlers and updates the formatter with the resulting render plan. Unknown
+// attributes and unsupported options are kept as plain text unless strict is true, in which case a *ParseError is
+// returned and the formatter is not modified.
+func (f *CustomFormatter) compile(template string, nodes []Node, custom CustomHandlers, style TemplateStyle, strict bool) error {
+	c, err := compileTemplate(template, nodes, custom, strict)
+	if err != nil {
+		return err
+	}
+	f.Template = c.template
+	f.Handlers = c.handlers
+	f.Attributes = c.attributes
+	f.compiled = c
+	f.custom = custom
+	f.style = style
+	return nil
+}
+
+// compileTemplate resolves attribute nodes to handlers and builds the render plan.
+func compileTemplate(template string, nodes []Node, custom CustomHandlers, strict bool) (*compiledTemplate, error) {
+	var handlers []Handler
+	attributes := make(Attributes)
+	fieldColumns := make(map[string]bool)
+	plan := make([]step, 0, len(nodes))
+	segments := make([]string, 0, len(nodes))
+
+	var add func(nodes []Node) error
+	add = func(nodes []Node) error {
+		for _, node := range nodes {
+			pos, end := node.Position()
+			var text, segment string
+			switch n := node.(type) {
+			case *GroupNode:
+				plan = append(plan, step{group: groupStart})
+				if err := add(n.Nodes); err != nil {
+					return err
+				}
+				plan = append(plan, step{group: groupEnd})
+				continue
+			case *TextNode:
+				text = n.Text
+				segment = strings.Replace(n.Text, "%", "%%", -1)
+			case *AttributeNode:
+				fn, known := lookupHandler(n.Name, custom)
+				filters, bad, err := compileFilters(n.Options)
+				switch {
+				case !known && strict:
+					return newParseError(template, n.namePos, n.Name, "unknown attribute")
+				case err != nil && strict:
+					offset := n.namePos + len(n.Name) + 1
+					for _, option := range n.Options[:bad] {
+						offset += len(option) + 1
+					}
+					msg := fmt.Sprintf("invalid filter %q (%s) for", n.Options[bad], err)
+					if err == errUnknownFilter {
+						msg = fmt.Sprintf("unknown filter %q for", n.Options[bad])
+					}
+					return newParseError(template, offset, n.Name, msg)
+				case known && err == nil:
+					if _, ok := custom[n.Name]; !ok {
+						if n.Name == "fields" {
+							fn = fieldsHandler(fieldColumns)
+						}
+						if key, ok := fieldKey(n.Name); ok {
+							fieldColumns[key] = true
+						}
+					}
+					if n.Name == "name" {
+						fieldColumns["name"] = true
+					}
+					st := step{node: n, handler: fn}
+					if len(filters) > 0 {
+						st.filters = filters
+
+						// Filtered values are strings, so other verbs (e.g. %5[process|upper]d) format them with %s.
+						if !strings.ContainsRune(stringVerbs, n.Verb) {
+							node := *n
+							node.Verb = 's'
+							node.directive = node.buildDirective("")
+							st.node = &node
+						}
+					}
+					if _, ok := custom[n.Name]; !ok {
+						if app, kind := lookupAppender(n.Name); app != nil && (st.filters != nil || fastPath(n, kind)) {
+							st.appender = app
+						}
+					}
+					handlers = append(handlers, fn)
+					attributes[n.Name] = true
+					plan = append(plan, st)
+					segments = append(segments, n.Directive())
+					continue
+				}
+				text = template[pos:end]
+				segment = text
+			}
+
+			// Merge adjacent text.
+			if last := len(plan) - 1; last >= 0 && plan[last].node == nil && plan[last].group == 0 {
+				plan[last].text += text
+			} else {
+				plan = append(plan, step{text: text})
+			}
+			segments = append(segments, segment)
+		}
+		return nil
+	}
+	if err := add(nodes); err != nil {
+		return nil, err
+	}
+
+	return &compiledTemplate{
+		source:       template,
+		template:     strings.Join(segments, ""),
+		handlers:     handlers,
+		attributes:   attributes,
+		fieldColumns: fieldColumns,
+		plan:         plan,
+	}, nil
+}
+
+// ParseTemplate parses the template string and prepares it for fmt.Sprintf() and keeps track of which handlers to use.
+// Unknown attributes and malformed directives are left in the template as plain text.
+//
+// :param template: Pre-processed formatting template (e.g. "%[message]s\n").
+//
+// :param custom: User-defined formatters evaluated before built-in formatters. Keys are attributes to look for in the
+// formatting string (e.g. "%[myFormatter]s") and values are formatting functions.
+func (f *CustomFormatter) ParseTemplate(template string, custom CustomHandlers) {
+	nodes, _ := parse(template, false)
+	f.compile(template, nodes, custom, PercentStyle, false)
+}
+
+// ParseTemplateE is like ParseTemplate but fails on problems instead of leaving them in the template. Unknown
+// attributes, unsupported verbs, unbalanced brackets and stray % signs are reported with a *ParseError. The formatter
+// is not modified if an error is returned.
+//
+// :param template: Pre-processed formatting template (e.g. "%[message]s\n").
+//
+// :param custom: User-defined formatters evaluated before built-in formatters.
+func (f *CustomFormatter) ParseTemplateE(template string, custom CustomHandlers) error {
+	nodes, err := parse(template, true)
+	if err != nil {
+		return err
+	}
+	return f.compile(template, nodes, custom, PercentStyle, true)
+}
@@ -0,0 +1,190 @@
+package lcf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomFormatter_ParseTemplateE(t *testing.T) {
+	testCases := []struct {
+		template  string
+		attribute string
+		offset    int
+		column    int
+		msg       string
+	}{
+		{"%[mesage]s\n", "mesage", 2, 3, "unknown attribute"},
+		{"%[levelName]s:%[message]z", "message", 24, 25, "unsupported verb 'z' after attribute"},
+		{"%[levelName]s:%[message]", "message", 24, 25, "missing verb after attribute"},
+		{"%[levelName s", "", 1, 2, "unbalanced bracket"},
+		{"%-7levelName]s", "", 0, 1, "stray % (use %% for a literal percent sign)"},
+		{"%-7]s", "", 3, 4, "unbalanced bracket"},
+		{"100% %[message]s", "", 3, 4, "stray % (use %% for a literal percent sign)"},
+		{"%[message]s %", "", 12, 13, "stray % (use %% for a literal percent sign)"},
+		{"%[]s", "", 1, 2, "empty attribute name"},
+		{"%[message]s\n\t%[nmae]s", "nmae", 15, 4, "unknown attribute"},
+		{"%{[%[name]s] %[message]s", "", 0, 1, "unclosed group (missing %})"},
+		{"%{a%}%{b%{c%}", "", 5, 6, "unclosed group (missing %})"},
+		{"%[name]s%} %[message]s", "", 8, 9, "unmatched %} (use %%} for a literal percent sign)"},
+		{"%{%[nmae]s%}", "nmae", 4, 5, "unknown attribute"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			formatter := &CustomFormatter{}
+			err := formatter.ParseTemplateE(tc.template, nil)
+			assert.Error(err)
+			parseErr, ok := err.(*ParseError)
+			assert.True(ok)
+			assert.Equal(tc.attribute, parseErr.Attribute)
+			assert.Equal(tc.offset, parseErr.Offset)
+			assert.Equal(tc.column, parseErr.Column)
+			assert.Equal(tc.msg, parseErr.Msg)
+			assert.Empty(formatter.Template)
+			assert.Empty(formatter.Handlers)
+		})
+	}
+}
+
+func TestCustomFormatter_ParseTemplateEValid(t *testing.T) {
+	assert := require.New(t)
+
+	formatter := &CustomFormatter{}
+	err := formatter.ParseTemplateE("100%% [%04[relativeCreated]d] %-7.4[one]d %[message]s\n", CustomHandlers{"one": handlerOne})
+	assert.NoError(err)
+	assert.Equal("100%% [%04d] %-7.4d %s\n", formatter.Template)
+	assert.Len(formatter.Handlers, 3)
+}
+
+func TestParseError_Error(t *testing.T) {
+	assert := require.New(t)
+
+	_, err := NewFormatterE("%[levelName]s\n\t%-20[nmae]s %[message]s\n", nil)
+	expected := "lcf: unknown attribute \"nmae\" at line 2 column 7 (offset 20)\n" +
+		"\t\t%-20[nmae]s %[message]s\n" +
+		"\t\t     ^"
+	assert.EqualError(err, expected)
+
+	formatter, err := NewFormatterE(Detailed, nil)
+	assert.NoError(err)
+	assert.Len(formatter.Handlers, 6)
+}
+
+func TestParse(t *testing.T) {
+	assert := require.New(t)
+
+	nodes, err := Parse("100%% [%-7.4[levelName]s] %+#[message|upper]v\n")
+	assert.NoError(err)
+	assert.Len(nodes, 5)
+
+	assert.Equal(&TextNode{Pos: 0, End: 7, Text: "100% ["}, nodes[0])
+	attr := nodes[1].(*AttributeNode)
+	assert.Equal("levelName", attr.Name)
+	assert.Equal("-", attr.Flags)
+	assert.Equal(7, attr.Width)
+	assert.Equal(4, attr.Precision)
+	assert.Equal('s', attr.Verb)
+	assert.Equal("%-7.4s", attr.Directive())
+	assert.Equal("%-7.4[levelName]s", attr.String())
+	pos, end := attr.Position()
+	assert.Equal(7, pos)
+	assert.Equal(24, end)
+
+	assert.Equal(&TextNode{Pos: 24, End: 26, Text: "] "}, nodes[2])
+	attr = nodes[3].(*AttributeNode)
+	assert.Equal("message", attr.Name)
+	assert.Equal([]string{"upper"}, attr.Options)
+	assert.Equal(-1, attr.Width)
+	assert.Equal(-1, attr.Precision)
+	assert.True(attr.HasFlag('#'))
+	assert.False(attr.HasFlag('-'))
+	assert.Equal("%+#v", attr.Directive())
+	assert.Equal("%+#[message|upper]v", attr.String())
+
+	_, err = Parse("%[message]s 50%")
+	assert.EqualError(err, "lcf: stray % (use %% for a literal percent sign) at line 1 column 15 (offset 14)\n"+
+		"\t%[message]s 50%\n"+
+		"\t              ^")
+}
+
+func TestCustomFormatter_ParseTemplateLenient(t *testing.T) {
+	assert := require.New(t)
+
+	formatter := &CustomFormatter{}
+	formatter.ParseTemplate("%%[message]s 100% %-5[nope]s %[message|nope]s %[message]s\n", nil)
+	assert.Equal("%%[message]s 100%% %-5[nope]s %[message|nope]s %s\n", formatter.Template)
+	assert.Len(formatter.Handlers, 1)
+	assert.Equal("%[message]s 100% %-5[nope]s %[message|nope]s Msg\n", formatter.Sprintf("Msg"))
+
+	// Parsing again replaces the previous template.
+	formatter.ParseTemplate(Basic, nil)
+	assert.Len(formatter.Handlers, 4)
+	assert.Len(formatter.Attributes, 4)
+
+	// Strict parsing rejects unknown filters and bad filter arguments.
+	err := formatter.ParseTemplateE("%[message|upper|uper]s", nil)
+	assert.EqualError(err, "lcf: unknown filter \"uper\" for \"message\" at line 1 column 17 (offset 16)\n"+
+		"\t%[message|upper|uper]s\n"+
+		"\t                ^")
+	err = formatter.ParseTemplateE("%[message|trunc:x]s", nil)
+	assert.EqualError(err, "lcf: invalid filter \"trunc:x\" (expected a non-negative integer argument) for \"message\" "+
+		"at line 1 column 11 (offset 10)\n\t%[message|trunc:x]s\n\t          ^")
+}
+
+func TestParseGroup(t *testing.T) {
+	assert := require.New(t)
+
+	nodes, err := Parse("%{[%{%[name]s%}] %}%[message]s")
+	assert.NoError(err)
+	assert.Len(nodes, 2)
+	group := nodes[0].(*GroupNode)
+	pos, end := group.Position()
+	assert.Equal(0, pos)
+	assert.Equal(19, end)
+	assert.Len(group.Nodes, 3)
+	assert.Equal(&TextNode{Pos: 2, End: 3, Text: "["}, group.Nodes[0])
+	inner := group.Nodes[1].(*GroupNode)
+	assert.Equal(3, inner.Pos)
+	assert.Equal(15, inner.End)
+	assert.Equal("name", inner.Nodes[0].(*AttributeNode).Name)
+	assert.Equal(&TextNode{Pos: 15, End: 17, Text: "] "}, group.Nodes[2])
+	assert.Equal("message", nodes[1].(*AttributeNode).Name)
+
+	// Lenient parsing keeps unmatched group markers as plain text.
+	formatter := &CustomFormatter{}
+	formatter.ParseTemplate("%{a%{%[message]s%} b%} %}", nil)
+	assert.Equal("a%s b %%}", formatter.Template)
+	assert.Equal("aMsg b %}", formatter.Sprintf("Msg"))
+	assert.Equal(" %}", formatter.Sprintf(""))
+	formatter.ParseTemplate("%{a %{%[message]s%} b", nil)
+	assert.Equal("%%{a %s b", formatter.Template)
+	assert.Equal("%{a Msg b", formatter.Sprintf("Msg"))
+	assert.Equal("%{a  b", formatter.Sprintf(""))
+}
+
+func TestParsePython(t *testing.T) {
+	assert := require.New(t)
+
+	nodes, err := Parse("%(asctime)s,%(msecs)03d %(levelname)-8s %(name)s: %(message)r %(custom)i\n")
+	assert.NoError(err)
+	var actual []string
+	for _, node := range nodes {
+		if attr, ok := node.(*AttributeNode); ok {
+			actual = append(actual, attr.String())
+		}
+	}
+	expected := []string{"%[ascTime]s", "%03[msecs]d", "%-8[levelName]s", "%[name]s", "%[message]q", "%[custom]d"}
+	assert.Equal(expected, actual)
+
+	_, err = Parse("%(levelname)-8z")
+	assert.EqualError(err, "lcf: unsupported conversion type 'z' after attribute \"levelname\" at line 1 column 15 (offset 14)\n"+
+		"\t%(levelname)-8z\n"+
+		"\t              ^")
+
+	_, err = Parse("%(message")
+	assert.EqualError(err, "lcf: unbalanced parenthesis at line 1 column 2 (offset 1)\n"+
+		"\t%(message\n"+
+		"\t ^")
+}
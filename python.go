@@ -0,0 +1,37 @@
+package lcf
+
+// Conversion types accepted after a Python logging style attribute (e.g. the "s" in "%(message)s").
+const pythonTypes = "diouxXeEfFgGcrsa"
+
+// PythonAttributes maps Python logging.LogRecord attribute names to lcf attribute names. Used when templates are written
+// in Python's syntax (e.g. "%(levelname)-8s") so one format string can be shared between Python and Go programs.
+//
+// Python's relativeCreated is in milliseconds so it is mapped to lcf's relativeMsecs, not relativeCreated (seconds).
+// Python's thread is mapped to the ID of the goroutine that logged the entry.
+var PythonAttributes = map[string]string{
+	"asctime":         "ascTime",
+	"created":         "created",
+	"filename":        "fileName",
+	"funcName":        "funcName",
+	"levelname":       "levelName",
+	"lineno":          "lineNo",
+	"message":         "message",
+	"module":          "module",
+	"msecs":           "msecs",
+	"name":            "name",
+	"pathname":        "pathName",
+	"process":         "process",
+	"relativeCreated": "relativeMsecs",
+	"thread":          "goroutine",
+}
+
+// pythonVerb returns the fmt verb equivalent to a Python conversion type.
+func pythonVerb(conversion rune) rune {
+	switch conversion {
+	case 'i', 'u':
+		return 'd'
+	case 'r', 'a':
+		return 'q'
+	}
+	return conversion
+}
@@ -0,0 +1,7 @@
+//go:build race
+// +build race
+
+package lcf
+
+// The race detector makes sync.Pool drop items at random, so allocation counts are not exact.
+const raceEnabled = true
@@ -0,0 +1,326 @@
+package lcf
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMask replaces secrets if Redaction.Mask is empty.
+const DefaultMask = "[REDACTED]"
+
+// Scrubber masks matches of a regular expression in log messages and string field values. If the expression has a
+// capture group only the text of the first group is masked (e.g. the token in "Bearer (\S+)").
+type Scrubber struct {
+	// Expression matching secrets.
+	Pattern *regexp.Regexp
+
+	// Characters at the end of each match kept after the mask (e.g. 4 for "****1234"). Matches not longer than this are
+	// masked completely.
+	KeepLast int
+}
+
+// Scrubbers for common secrets.
+var (
+	// ScrubCreditCards masks card numbers of 13 to 19 digits, optionally grouped with spaces or dashes, keeping the last
+	// four digits.
+	ScrubCreditCards = Scrubber{Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), KeepLast: 4}
+
+	// ScrubEmails masks email addresses.
+	ScrubEmails = Scrubber{Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)}
+
+	// ScrubJWTs masks JSON Web Tokens.
+	ScrubJWTs = Scrubber{Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)}
+
+	// ScrubBearerTokens masks the tokens of "Bearer" authorization headers.
+	ScrubBearerTokens = Scrubber{Pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)`)}
+)
+
+// KeyRule masks the values of fields whose keys match a pattern but keeps their last characters (e.g. "****1234").
+type KeyRule struct {
+	// Key pattern matched like Redaction.Keys.
+	Pattern string
+
+	// Characters at the end of string and number values kept after the mask. Values not longer than this and other
+	// values are masked completely.
+	KeepLast int
+}
+
+// Redaction masks secrets in log entries before they are formatted, so every attribute including custom handlers
+// reading entry.Data and entry.Message sees the masked values. The logged entry itself is not modified.
+//
+// Field values of every type are redacted: errors, fmt.Stringers, byte slices and numbers are scrubbed as text. Maps,
+// structs and slices holding a redacted key or a scrubbed secret at any depth are masked completely, unless
+// CustomFormatter.Flatten is enabled in which case %[fields]s masks only their nested keys and values.
+//
+//	formatter.Redact = lcf.Redaction{
+//		Keys:        []string{"password", "*_token", "authorization"},
+//		PartialKeys: []lcf.KeyRule{{Pattern: "card", KeepLast: 4}},
+//		Scrubbers:   []lcf.Scrubber{lcf.ScrubCreditCards, lcf.ScrubJWTs},
+//	}
+type Redaction struct {
+	// Keys of fields whose values are masked completely. Patterns use path.Match syntax (e.g. "*_token") and are
+	// matched case-insensitively. Nested keys match too, and flattened keys match by their last element (e.g.
+	// "password" for "user.password").
+	Keys []string
+
+	// Keys of fields whose values are masked except for their last characters. Keys matching Keys are masked
+	// completely.
+	PartialKeys []KeyRule
+
+	// Masks matches in the message and in field values.
+	Scrubbers []Scrubber
+
+	// Text replacing secrets. DefaultMask if empty.
+	Mask string
+}
+
+// redactedValue replaces a map, struct, slice or pointer holding secrets in the data of redacted entries. It prints as
+// the mask while %[fields]s expands the original value with its nested secrets masked when flattening.
+type redactedValue struct {
+	value interface{}
+	mask  string
+}
+
+// String returns the mask.
+func (v redactedValue) String() string {
+	return v.mask
+}
+
+// MarshalText returns the mask so encoding/json writes it too.
+func (v redactedValue) MarshalText() ([]byte, error) {
+	return []byte(v.mask), nil
+}
+
+// enabled returns true if the redaction has keys or scrubbers.
+func (r *Redaction) enabled() bool {
+	return len(r.Keys) > 0 || len(r.PartialKeys) > 0 || len(r.Scrubbers) > 0
+}
+
+// apply returns a copy of the entry with secrets masked. Returns the entry if nothing was masked.
+func (r *Redaction) apply(entry *logrus.Entry) *logrus.Entry {
+	message := r.scrub(entry.Message)
+	var data logrus.Fields
+	for key, value := range entry.Data {
+		masked, ok := r.field(key, value)
+		if !ok {
+			continue
+		}
+		if data == nil {
+			data = make(logrus.Fields, len(entry.Data))
+			for k, v := range entry.Data {
+				data[k] = v
+			}
+		}
+		data[key] = masked
+	}
+	if data == nil && message == entry.Message {
+		return entry
+	}
+	redacted := *entry
+	redacted.Message = message
+	if data != nil {
+		redacted.Data = data
+	}
+	return &redacted
+}
+
+// field returns the masked value of a field and true if it has a redacted key or holds secrets.
+func (r *Redaction) field(key string, value interface{}) (interface{}, bool) {
+	if keepLast, ok := r.matchKey(key); ok {
+		text, ok := value.(string)
+		if !ok {
+			text = fmt.Sprint(value)
+		}
+
+		// Maps, structs, slices and pointers are masked completely so their end does not show.
+		switch kind := reflect.ValueOf(value).Kind(); {
+		case kind == reflect.String, reflect.Bool <= kind && kind <= reflect.Complex128:
+		default:
+			keepLast = 0
+		}
+		return r.mask(text, keepLast), true
+	}
+	if text, ok := scalarText(value); ok {
+		if scrubbed := r.scrub(text); scrubbed != text {
+			return scrubbed, true
+		}
+		return value, false
+	}
+	if r.holdsSecret(reflect.ValueOf(value), make(map[flattenRef]bool)) {
+		return redactedValue{value, r.mask("", 0)}, true
+	}
+	return value, false
+}
+
+// scalarText returns the text of a value fmt does not print element by element and true, or false for maps, structs,
+// slices and pointers.
+func scalarText(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case error, fmt.Stringer:
+		return fmt.Sprint(v), true
+	}
+	if kind := reflect.ValueOf(value).Kind(); reflect.Bool <= kind && kind <= reflect.Complex128 {
+		return fmt.Sprint(value), true
+	}
+	return "", false
+}
+
+// holdsSecret returns true if a map, struct, slice or pointer holds a redacted key or a scrubbed secret at any depth.
+// Values already in seen are skipped so cycles end.
+func (r *Redaction) holdsSecret(v reflect.Value, seen map[flattenRef]bool) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return false
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return false
+		}
+		ref := flattenRef{v.Pointer(), v.Type()}
+		if seen[ref] {
+			return false
+		}
+		seen[ref] = true
+	}
+	if v.CanInterface() {
+		if text, ok := scalarText(v.Interface()); ok {
+			return len(r.Scrubbers) > 0 && r.scrub(text) != text
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return r.holdsSecret(v.Elem(), seen)
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			if r.holdsSecret(k, seen) || r.holdsSecret(v.MapIndex(k), seen) {
+				return true
+			}
+			if k.Kind() == reflect.String {
+				if _, ok := r.matchKey(k.String()); ok {
+					return true
+				}
+			} else if k.CanInterface() {
+				if _, ok := r.matchKey(fmt.Sprint(k.Interface())); ok {
+					return true
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if r.holdsSecret(v.Index(i), seen) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		// Unexported fields are checked too because fmt prints them.
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := sf.Tag.Get("json")
+			if comma := strings.IndexByte(name, ','); comma >= 0 {
+				name = name[:comma]
+			}
+			if _, ok := r.matchKey(sf.Name); ok {
+				return true
+			}
+			if _, ok := r.matchKey(name); ok && name != "" && name != "-" {
+				return true
+			}
+			if r.holdsSecret(v.Field(i), seen) {
+				return true
+			}
+		}
+	case reflect.String:
+		// Unexported strings.
+		return len(r.Scrubbers) > 0 && r.scrub(v.String()) != v.String()
+	}
+	return false
+}
+
+// matchKey returns the characters kept of a field's value and true if its key matches one of Keys or PartialKeys.
+// Dotted keys of flattened fields (e.g. "user.password") also match by their last element.
+func (r *Redaction) matchKey(key string) (int, bool) {
+	if len(r.Keys) == 0 && len(r.PartialKeys) == 0 {
+		return 0, false
+	}
+	key = strings.ToLower(key)
+	last := key[strings.LastIndexByte(key, '.')+1:]
+	for _, pattern := range r.Keys {
+		if matchPattern(pattern, key, last) {
+			return 0, true
+		}
+	}
+	for _, rule := range r.PartialKeys {
+		if matchPattern(rule.Pattern, key, last) {
+			return rule.KeepLast, true
+		}
+	}
+	return 0, false
+}
+
+// matchPattern returns true if a key pattern matches any of the lower case names case-insensitively.
+func matchPattern(pattern string, names ...string) bool {
+	pattern = strings.ToLower(pattern)
+	for _, name := range names {
+		if matched, _ := path.Match(pattern, name); matched || pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// scrub masks the matches of all Scrubbers in text.
+func (r *Redaction) scrub(text string) string {
+	for _, scrubber := range r.Scrubbers {
+		if scrubber.Pattern == nil {
+			continue
+		}
+		matches := scrubber.Pattern.FindAllStringSubmatchIndex(text, -1)
+		if matches == nil {
+			continue
+		}
+		var b strings.Builder
+		end := 0
+		for _, match := range matches {
+			start, stop := match[0], match[1]
+			if len(match) >= 4 && match[2] >= 0 {
+				start, stop = match[2], match[3]
+			}
+			b.WriteString(text[end:start])
+			b.WriteString(r.mask(text[start:stop], scrubber.KeepLast))
+			end = stop
+		}
+		b.WriteString(text[end:])
+		text = b.String()
+	}
+	return text
+}
+
+// mask returns the mask followed by the last keepLast characters of a secret.
+func (r *Redaction) mask(secret string, keepLast int) string {
+	mask := r.Mask
+	if mask == "" {
+		mask = DefaultMask
+	}
+	if keepLast <= 0 || utf8.RuneCountInString(secret) <= keepLast {
+		return mask
+	}
+	i := len(secret)
+	for n := 0; n < keepLast; n++ {
+		_, size := utf8.DecodeLastRuneInString(secret[:i])
+		i -= size
+	}
+	return mask + secret[i:]
+}
@@ -0,0 +1,328 @@
+package lcf
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Buffers that grew larger than this while rendering are not put back in the pool.
+const maxPooledBuffer = 64 << 10
+
+// Used for writing padding without allocating.
+const (
+	spaces = "                                "
+	zeros  = "00000000000000000000000000000000"
+)
+
+// renderState holds the buffers used while rendering one log entry. They are pooled so rendering does not allocate.
+type renderState struct {
+	buffer  bytes.Buffer
+	scratch []byte   // For strconv.Append*() and time.Time.AppendFormat().
+	keys    []string // For sorting field keys.
+	groups  []groupState
+
+	// Fields omitted from %[fields]s by the template being rendered.
+	fieldColumns map[string]bool
+
+	// Caller of the entry being rendered, looked up by the first caller attribute.
+	callerFrame runtime.Frame
+	callerFound bool
+	callerDone  bool
+}
+
+var renderStatePool = sync.Pool{New: func() interface{} { return new(renderState) }}
+
+func getRenderState() *renderState {
+	s := renderStatePool.Get().(*renderState)
+	s.buffer.Reset()
+	s.groups = s.groups[:0]
+	s.callerDone = false
+	return s
+}
+
+func putRenderState(s *renderState) {
+	if s.buffer.Cap() <= maxPooledBuffer {
+		renderStatePool.Put(s)
+	}
+}
+
+// groupState tracks an open conditional group while rendering.
+type groupState struct {
+	start      int  // Render buffer length when the group was opened.
+	attributes bool // True if the group contains attributes.
+	filled     bool // True if at least one of them was not empty.
+}
+
+// openGroup starts a conditional group at the current end of the render buffer.
+func (s *renderState) openGroup() {
+	s.groups = append(s.groups, groupState{start: s.buffer.Len()})
+}
+
+// closeGroup ends the innermost conditional group. Everything written since it was opened is removed if the group has
+// attributes and all of them were empty.
+func (s *renderState) closeGroup() {
+	if len(s.groups) == 0 {
+		return
+	}
+	g := s.groups[len(s.groups)-1]
+	s.groups = s.groups[:len(s.groups)-1]
+	if !g.attributes {
+		return
+	}
+	if !g.filled {
+		s.buffer.Truncate(g.start)
+	}
+	s.markValue(!g.filled)
+}
+
+// markValue records whether an attribute rendered empty in the innermost conditional group.
+func (s *renderState) markValue(empty bool) {
+	if len(s.groups) > 0 {
+		g := &s.groups[len(s.groups)-1]
+		g.attributes = true
+		g.filled = g.filled || !empty
+	}
+}
+
+// isEmptyValue returns true if a handler's value is nil, an empty string or a missing field.
+func isEmptyValue(value interface{}) bool {
+	if v, ok := value.(fieldValue); ok {
+		return v.missing
+	}
+	return value == nil || value == ""
+}
+
+// appender writes a built-in attribute's value straight into the render buffer instead of boxing it in an interface{}.
+type appender func(*renderState, *logrus.Entry, *CustomFormatter)
+
+// step is one instruction of a compiled template. It writes either literal text or an attribute's value.
+type step struct {
+	group    int                   // groupStart or groupEnd if the step opens or closes a conditional group.
+	text     string                // Literal text to write if node is nil.
+	node     *AttributeNode        // Attribute to write.
+	handler  Handler               // Attribute's handler.
+	filters  []func(string) string // Filter pipeline applied to the attribute's value formatted as a string.
+	appender appender              // Used instead of the handler for built-in attributes when fmt is not needed.
+}
+
+// Values of step.group.
+const (
+	groupStart = iota + 1
+	groupEnd
+)
+
+// fastPath returns true if the attribute's directive can be handled without fmt for a value of the given kind ('s' for
+// strings and 'd' for integers).
+func fastPath(n *AttributeNode, kind rune) bool {
+	if n.Verb != kind && n.Verb != 'v' {
+		return false
+	}
+	for i := 0; i < len(n.Flags); i++ {
+		if c := n.Flags[i]; c != '-' && !(c == '0' && kind == 'd') {
+			return false
+		}
+	}
+	return kind == 's' || n.Precision < 0
+}
+
+// colorsEnabled returns true if colors should be written.
+func (f *CustomFormatter) colorsEnabled() bool {
+	return f.ForceColors || !f.DisableColors
+}
+
+// render writes the entry formatted by the compiled template to the render buffer.
+func (f *CustomFormatter) render(s *renderState, entry *logrus.Entry, c *compiledTemplate) error {
+	s.fieldColumns = c.fieldColumns
+	for i := range c.plan {
+		st := &c.plan[i]
+		switch {
+		case st.group == groupStart:
+			s.openGroup()
+			continue
+		case st.group == groupEnd:
+			s.closeGroup()
+			continue
+		case st.node == nil:
+			s.buffer.WriteString(st.text)
+			continue
+		case st.filters != nil:
+			if err := f.writeFiltered(s, st, entry); err != nil {
+				return err
+			}
+			continue
+		case st.appender != nil:
+			start := s.buffer.Len()
+			st.appender(s, entry, f)
+			s.markValue(s.buffer.Len() == start)
+			f.finishValue(s, st.node, start)
+			continue
+		}
+		value, err := st.handler(entry, f)
+		if err != nil {
+			return err
+		}
+		s.markValue(isEmptyValue(value))
+		f.writeValue(s, st.node, value)
+	}
+	return nil
+}
+
+// writeFiltered writes an attribute's value after passing it through the attribute's filter pipeline. The filtered
+// value is formatted as a string.
+func (f *CustomFormatter) writeFiltered(s *renderState, st *step, entry *logrus.Entry) error {
+	start := s.buffer.Len()
+	if st.appender != nil {
+		st.appender(s, entry, f)
+	} else {
+		value, err := st.handler(entry, f)
+		if err != nil {
+			return err
+		}
+		appendValue(s, value)
+	}
+	value := s.buffer.String()[start:]
+	s.buffer.Truncate(start)
+	f.writeFilteredValue(s, st, value)
+	return nil
+}
+
+// writeFilteredValue passes a value through the step's filter pipeline and writes it.
+func (f *CustomFormatter) writeFilteredValue(s *renderState, st *step, value string) {
+	for _, filter := range st.filters {
+		value = filter(value)
+	}
+	s.markValue(value == "")
+	f.writeValue(s, st.node, value)
+}
+
+// writeValue formats one attribute's value like fmt.Sprintf() does with the attribute's directive. When colors are
+// enabled ANSI color sequences in string values are excluded from the padding.
+func (f *CustomFormatter) writeValue(s *renderState, n *AttributeNode, value interface{}) {
+	start := s.buffer.Len()
+	switch v := value.(type) {
+	case string:
+		if fastPath(n, 's') || f.colorsEnabled() && n.Verb == 's' && hasEscape(v) {
+			s.buffer.WriteString(v)
+			f.finishValue(s, n, start)
+			return
+		}
+	case int:
+		if fastPath(n, 'd') {
+			s.scratch = strconv.AppendInt(s.scratch[:0], int64(v), 10)
+			s.buffer.Write(s.scratch)
+			f.finishValue(s, n, start)
+			return
+		}
+	}
+	fmt.Fprintf(&s.buffer, n.Directive(), value)
+}
+
+// finishValue truncates and pads the value written to the render buffer since start according to the attribute's
+// precision and width.
+func (f *CustomFormatter) finishValue(s *renderState, n *AttributeNode, start int) {
+	value := s.buffer.Bytes()[start:]
+	colored := f.colorsEnabled() && bytes.IndexByte(value, '\033') >= 0
+
+	// Truncate strings to precision. Only visible runes count and color sequences are kept intact.
+	if n.Precision >= 0 && n.Verb != 'd' {
+		if !colored && f.Ellipsis == "" {
+			if i := runeOffset(value, n.Precision); i < len(value) {
+				s.buffer.Truncate(start + i)
+				value = value[:i]
+			}
+		} else if visibleRuneCount(value) > n.Precision {
+			s.scratch = append(s.scratch[:0], value...)
+			s.buffer.Truncate(start)
+			writeTruncated(&s.buffer, s.scratch, n.Precision, f.Ellipsis)
+			value = s.buffer.Bytes()[start:]
+		}
+	}
+
+	// Determine padding. Width is measured in terminal columns so wide characters and combining marks do not throw off
+	// alignment.
+	if n.Width <= 0 {
+		return
+	}
+	padding := n.Width - displayWidth(value, colored)
+	if padding <= 0 {
+		return
+	}
+
+	// Pad on the right.
+	if n.HasFlag('-') {
+		writePadding(&s.buffer, spaces, padding)
+		return
+	}
+
+	// Pad on the left by shifting the value to the right. Zero padding goes after the sign.
+	pad := spaces
+	if n.HasFlag('0') && n.Verb != 's' {
+		pad = zeros
+	}
+	end := s.buffer.Len()
+	writePadding(&s.buffer, pad, padding)
+	b := s.buffer.Bytes()
+	if pad == zeros && (b[start] == '-' || b[start] == '+') {
+		start++
+	}
+	copy(b[start+padding:], b[start:end])
+	for i := start; i < start+padding; i++ {
+		b[i] = pad[0]
+	}
+}
+
+// writePadding writes n bytes of pad (spaces or zeros) to the buffer.
+func writePadding(buffer *bytes.Buffer, pad string, n int) {
+	for n > len(pad) {
+		buffer.WriteString(pad)
+		n -= len(pad)
+	}
+	buffer.WriteString(pad[:n])
+}
+
+// runeOffset returns the byte offset in b after n runes, or len(b) if b is shorter.
+func runeOffset(b []byte, n int) int {
+	i := 0
+	for ; n > 0 && i < len(b); n-- {
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
+	}
+	return i
+}
+
+// appendValue writes a field value like fmt's %v verb does. Common types are written without fmt.
+func appendValue(s *renderState, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.buffer.WriteString(v)
+		return
+	case int:
+		s.scratch = strconv.AppendInt(s.scratch[:0], int64(v), 10)
+	case int64:
+		s.scratch = strconv.AppendInt(s.scratch[:0], v, 10)
+	case int32:
+		s.scratch = strconv.AppendInt(s.scratch[:0], int64(v), 10)
+	case uint:
+		s.scratch = strconv.AppendUint(s.scratch[:0], uint64(v), 10)
+	case uint64:
+		s.scratch = strconv.AppendUint(s.scratch[:0], v, 10)
+	case uint32:
+		s.scratch = strconv.AppendUint(s.scratch[:0], uint64(v), 10)
+	case float64:
+		s.scratch = strconv.AppendFloat(s.scratch[:0], v, 'g', -1, 64)
+	case float32:
+		s.scratch = strconv.AppendFloat(s.scratch[:0], float64(v), 'g', -1, 32)
+	case bool:
+		s.scratch = strconv.AppendBool(s.scratch[:0], v)
+	default:
+		fmt.Fprint(&s.buffer, value)
+		return
+	}
+	s.buffer.Write(s.scratch)
+}
@@ -0,0 +1,101 @@
+package lcf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomFormatter_writeValue(t *testing.T) {
+	values := []interface{}{"", "abc", "ñandú", 0, 7, -42, 12345, 1.5, true, nil, errors.New("err")}
+	directives := []string{
+		"%s", "%5s", "%-5s", "%.2s", "%5.2s", "%-5.1s", "%.0s", "%05s",
+		"%d", "%5d", "%-5d", "%05d", "%-05d", "%+d", "%.3d",
+		"%v", "%4v", "%-4v", "%q", "%x",
+	}
+
+	for _, directive := range directives {
+		formatter := NewFormatter(directive[:len(directive)-1]+"[message]"+directive[len(directive)-1:], nil)
+		formatter.DisableColors = true
+		for _, value := range values {
+			t.Run(fmt.Sprintf("%s %#v", directive, value), func(t *testing.T) {
+				assert := require.New(t)
+				assert.Equal(fmt.Sprintf(directive, value), formatter.Sprintf(value))
+			})
+		}
+	}
+}
+
+func TestVisibleWidth(t *testing.T) {
+	testCases := map[string]int{
+		"":                         0,
+		"abc":                      3,
+		"\033[31mabc\033[0m":       3,
+		"\033[1;31m日本\033[0m":      4,
+		"\033[0m":                  0,
+		"\033[m":                   2, // Not matched as a color sequence. The escape character has no width.
+		"\033[31mA\033[0m\033[36m": 1,
+	}
+	for input, expected := range testCases {
+		t.Run(fmt.Sprintf("%q", input), func(t *testing.T) {
+			assert := require.New(t)
+			assert.Equal(expected, visibleWidth([]byte(input)))
+		})
+	}
+}
+
+func TestCustomFormatter_DisplayWidthPadding(t *testing.T) {
+	for _, forceColors := range []bool{false, true} {
+		t.Run(fmt.Sprintf("forceColors:%v", forceColors), func(t *testing.T) {
+			assert := require.New(t)
+			formatter := NewFormatter("%-10[message]s|%6[name]s|", nil)
+			formatter.ForceColors = forceColors
+			formatter.DisableColors = !forceColors
+			entry := newBenchmarkEntry(formatter)
+			entry.Message = "日本語"
+			entry.Data["name"] = "e\u0301té"
+			actual, err := formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal("日本語    |   e\u0301té|", string(actual))
+		})
+	}
+}
+
+func TestCustomFormatter_Precision(t *testing.T) {
+	testCases := []struct {
+		template    string
+		ellipsis    string
+		forceColors bool
+		expected    string
+	}{
+		{"%-7.4[levelName]s|", "", true, "\033[33mWARN\033[0m   |"},
+		{"%7.4[levelName]s|", "", true, "   \033[33mWARN\033[0m|"},
+		{"%.20[levelName]s|", "", true, "\033[33mWARNING\033[0m|"},
+		{"%-7.4[levelName]s|", "…", true, "\033[33mWAR…\033[0m   |"},
+		{"%-7.4[levelName]s|", "…", false, "WAR…   |"},
+		{"%.7[levelName]s|", "…", false, "WARNING|"},
+		{"%.0[levelName]s|", "…", true, "\033[33m\033[0m|"},
+		{"%-8.6[message]s|", "...", false, "Sam...  |"},
+		{"%.4[fields]s|", "", true, " \033[33ma\033[0m=b\033[33m\033[0m\033[33m\033[0m|"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s %q %v", tc.template, tc.ellipsis, tc.forceColors), func(t *testing.T) {
+			assert := require.New(t)
+			formatter := NewFormatter(tc.template, nil)
+			formatter.ForceColors = tc.forceColors
+			formatter.DisableColors = !tc.forceColors
+			formatter.Ellipsis = tc.ellipsis
+			actual, err := formatter.Format(newBenchmarkEntry(formatter))
+			assert.NoError(err)
+			assert.Equal(tc.expected, string(actual))
+
+			// Sprintf agrees with Format.
+			value, err := formatter.Handlers[0](newBenchmarkEntry(formatter), formatter)
+			assert.NoError(err)
+			assert.Equal(tc.expected, formatter.Sprintf(value))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package lcf
+
+import (
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sink is one output of a SinkHook: a writer and the formatter used for it.
+type Sink struct {
+	// Writer the formatted entries are written to.
+	Writer io.Writer
+
+	// Formats entries for the writer, usually with colors decided for it (see NewSink).
+	Formatter logrus.Formatter
+
+	// Levels written to the writer. All levels if empty.
+	Levels []logrus.Level
+}
+
+// NewSink returns a Sink writing entries formatted by formatter to w, with colors only if the environment and w allow
+// them (see CustomFormatter.ForWriter). Use a different formatter per sink for a different template per sink.
+//
+// :param w: Writer the formatted entries are written to.
+//
+// :param formatter: Formatter whose template and settings are used for the sink.
+//
+// :param levels: Levels written to w. All levels if none are given.
+func NewSink(w io.Writer, formatter *CustomFormatter, levels ...logrus.Level) Sink {
+	return Sink{Writer: w, Formatter: formatter.ForWriter(w), Levels: levels}
+}
+
+// SinkHook is a logrus hook writing every entry to several sinks, rendering it once per sink. Set the logger's output
+// to ioutil.Discard so entries are not written twice:
+//
+//	console := lcf.NewFormatter("%[shortLevelName]s %[message]s%[fields]s\n", nil)
+//	detailed := lcf.NewFormatter(lcf.Detailed, nil)
+//	logger.AddHook(lcf.NewSinkHook(lcf.NewSink(os.Stderr, console), lcf.NewSink(file, detailed)))
+//	logger.Out = ioutil.Discard
+type SinkHook struct {
+	sinks []Sink
+	mutex sync.Mutex
+}
+
+// NewSinkHook returns a SinkHook writing to the given sinks.
+//
+// :param sinks: Outputs of the hook.
+func NewSinkHook(sinks ...Sink) *SinkHook {
+	return &SinkHook{sinks: sinks}
+}
+
+// Levels returns the levels of all sinks. Called by logrus.
+func (h *SinkHook) Levels() []logrus.Level {
+	var levels []logrus.Level
+	for _, level := range logrus.AllLevels {
+		for i := range h.sinks {
+			if h.sinks[i].accepts(level) {
+				levels = append(levels, level)
+				break
+			}
+		}
+	}
+	return levels
+}
+
+// Fire formats the entry for every sink accepting its level and writes it. All sinks are written to even if some fail.
+// The first error is returned. Called by logrus.
+func (h *SinkHook) Fire(entry *logrus.Entry) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var firstErr error
+	for i := range h.sinks {
+		sink := &h.sinks[i]
+		if !sink.accepts(entry.Level) {
+			continue
+		}
+		formatted, err := sink.Formatter.Format(entry)
+		if err == nil {
+			_, err = sink.Writer.Write(formatted)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// accepts returns true if entries of the level are written to the sink.
+func (s *Sink) accepts(level logrus.Level) bool {
+	if len(s.Levels) == 0 {
+		return true
+	}
+	for _, l := range s.Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package lcf
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestSinkHook(t *testing.T) {
+	assert := require.New(t)
+	defer setenv("NO_COLOR", "")()
+	defer setenv("CLICOLOR_FORCE", "")()
+	defer setenv("FORCE_COLOR", "")()
+
+	console := NewFormatter("%[shortLevelName]s %[message]s%[fields]s\n", nil)
+	console.ForceColors = true
+	detailed := NewFormatter("%[levelName]s %[name]s: %[message]s%[fields]s\n", nil)
+	detailed.ForceColors = true // Still uncolored in the file.
+
+	var consoleOut, fileOut, errorsOut bytes.Buffer
+	hook := NewSinkHook(
+		Sink{Writer: &consoleOut, Formatter: console},
+		NewSink(&fileOut, detailed),
+		NewSink(&errorsOut, detailed, logrus.ErrorLevel, logrus.FatalLevel),
+	)
+	assert.Equal(logrus.AllLevels, hook.Levels())
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Level = logrus.DebugLevel
+	logger.Hooks.Add(hook)
+	logger.WithFields(logrus.Fields{"name": "LogMsgs", "a": "b"}).Info("Sample info.")
+	logger.Error("Sample error.")
+
+	assert.Equal("\033[32mINFO\033[0m Sample info. \033[32ma\033[0m=b \033[32mname\033[0m=LogMsgs\n"+
+		"\033[31mERRO\033[0m Sample error.\n", consoleOut.String())
+	assert.Equal("INFO LogMsgs: Sample info. a=b\nERROR : Sample error.\n", fileOut.String())
+	assert.Equal("ERROR : Sample error.\n", errorsOut.String())
+}
+
+func TestSinkHook_Levels(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter(Message, nil)
+	hook := NewSinkHook(
+		NewSink(ioutil.Discard, formatter, logrus.WarnLevel),
+		NewSink(ioutil.Discard, formatter, logrus.ErrorLevel, logrus.WarnLevel),
+	)
+	assert.Equal([]logrus.Level{logrus.ErrorLevel, logrus.WarnLevel}, hook.Levels())
+	assert.Empty(NewSinkHook().Levels())
+}
+
+func TestSinkHook_Error(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter(Message, nil)
+	var buffer bytes.Buffer
+	hook := NewSinkHook(NewSink(failingWriter{}, formatter), NewSink(&buffer, formatter))
+
+	entry := newBenchmarkEntry(formatter)
+	assert.EqualError(hook.Fire(entry), "disk full")
+	assert.Equal("Sample warn 2.\n", buffer.String())
+}
@@ -0,0 +1,144 @@
+package lcf
+
+import "io"
+
+// States of escapeParser.
+const (
+	parseText         = iota // Not in an escape sequence.
+	parseEscape              // After ESC.
+	parseIntermediate        // After ESC and intermediate bytes (e.g. "\033(").
+	parseCSI                 // In a control sequence (e.g. "\033[31m").
+	parseString              // In an OSC, DCS, SOS, PM or APC string (e.g. "\033]0;title\a").
+	parseStringEscape        // After ESC in a string, which ends it if followed by a backslash.
+)
+
+// Results of escapeParser.next.
+const (
+	escapeText = iota // The byte is text.
+	escapeSkip        // The byte is part of an escape sequence.
+	escapeSGR         // The byte ends an SGR sequence (e.g. "\033[1;31m") whose parameters are in params.
+)
+
+// escapeParser finds ANSI escape sequences (SGR colors and other CSI sequences, OSC strings such as terminal titles
+// and hyperlinks, and other ESC sequences) in a byte stream one byte at a time, so sequences may be split between
+// writes.
+type escapeParser struct {
+	state  int
+	params []byte // Parameter bytes of the control sequence being parsed.
+}
+
+// next returns whether c is text, part of an escape sequence, or the end of an SGR sequence.
+func (p *escapeParser) next(c byte) int {
+	switch p.state {
+	case parseText:
+		if c == '\033' {
+			p.state = parseEscape
+			return escapeSkip
+		}
+		return escapeText
+	case parseEscape:
+		switch {
+		case c == '[':
+			p.state = parseCSI
+			p.params = p.params[:0]
+		case c == ']' || c == 'P' || c == 'X' || c == '^' || c == '_':
+			p.state = parseString
+		case 0x20 <= c && c <= 0x2F:
+			p.state = parseIntermediate
+		case 0x30 <= c && c <= 0x7E:
+			p.state = parseText
+		case c != '\033':
+			p.state = parseText // Not an escape sequence, keep control characters such as newlines.
+			return escapeText
+		}
+	case parseIntermediate:
+		return p.untilFinal(c, 0x30)
+	case parseCSI:
+		if c == 'm' && isSGRParams(p.params) {
+			p.state = parseText
+			return escapeSGR
+		}
+		if 0x30 <= c && c <= 0x3F {
+			p.params = append(p.params, c)
+		}
+		return p.untilFinal(c, 0x40)
+	case parseString:
+		switch c {
+		case '\a':
+			p.state = parseText
+		case '\033':
+			p.state = parseStringEscape
+		}
+	case parseStringEscape:
+		switch c {
+		case '\\':
+			p.state = parseText
+		case '\033':
+		default:
+			p.state = parseString
+		}
+	}
+	return escapeSkip
+}
+
+// untilFinal ends a sequence at a final byte (from first through 0x7E). Bytes that cannot be part of the sequence end
+// it and are text.
+func (p *escapeParser) untilFinal(c byte, first byte) int {
+	switch {
+	case first <= c && c <= 0x7E:
+		p.state = parseText
+	case c == '\033':
+		p.state = parseEscape
+	case c < 0x20 || c > 0x7E:
+		p.state = parseText
+		return escapeText
+	}
+	return escapeSkip
+}
+
+// isSGRParams returns true if the parameters of a control sequence ending with "m" are those of an SGR sequence
+// (digits separated by semicolons or colons).
+func isSGRParams(params []byte) bool {
+	for _, c := range params {
+		if (c < '0' || c > '9') && c != ';' && c != ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// stripANSIWriter removes escape sequences from everything written to it. See StripANSIWriter.
+type stripANSIWriter struct {
+	w      io.Writer
+	parser escapeParser
+	buffer []byte
+}
+
+// StripANSIWriter returns a writer removing ANSI escape sequences (SGR colors and other CSI sequences, OSC strings such
+// as terminal titles and hyperlinks, and other ESC sequences) from everything written to it before writing it to w.
+// Sequences split between Write calls are removed too. Like most writers it is not safe for concurrent use.
+//
+// Use it to write colored output of any formatter to files, CI logs or log shippers:
+//
+//	logger.Out = lcf.StripANSIWriter(file)
+//
+// :param w: Writer the text without escape sequences is written to.
+func StripANSIWriter(w io.Writer) io.Writer {
+	return &stripANSIWriter{w: w}
+}
+
+// Write writes p without escape sequences. Returns len(p) if the text could be written.
+func (s *stripANSIWriter) Write(p []byte) (int, error) {
+	s.buffer = s.buffer[:0]
+	for _, c := range p {
+		if s.parser.next(c) == escapeText {
+			s.buffer = append(s.buffer, c)
+		}
+	}
+	if len(s.buffer) > 0 {
+		if _, err := s.w.Write(s.buffer); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,76 @@
+package lcf
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripANSIWriter(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"plain text\n", "plain text\n"},
+		{"\033[31mERROR\033[0m:main:Failed. \033[31ma\033[0m=b\n", "ERROR:main:Failed. a=b\n"},
+		{"\033[1;38;5;208mWARN\033[0m \033[38;2;255;135;0mx\033[m", "WARN x"},
+		{"\033[2K\033[1Gprogress\033[?25l", "progress"},
+		{"\033]0;title\a\033]8;;https://example.com\033\\link\033]8;;\033\\", "link"},
+		{"\033P+q\033\\\033_apc\a\033(Bdone\0337", "done"},
+		{"\033\033[31mx", "x"},
+		{"tab\033[3\t1mx", "tab\t1mx"},
+		{"\033\ncolumn 日本", "\ncolumn 日本"},
+		{"\033]0;a\033b\033\\c", "c"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q", tc.input), func(t *testing.T) {
+			assert := require.New(t)
+
+			// Split at every offset to exercise sequences spanning Write calls.
+			for split := 0; split <= len(tc.input); split++ {
+				var buffer bytes.Buffer
+				w := StripANSIWriter(&buffer)
+				n, err := w.Write([]byte(tc.input[:split]))
+				assert.NoError(err)
+				assert.Equal(split, n)
+				n, err = w.Write([]byte(tc.input[split:]))
+				assert.NoError(err)
+				assert.Equal(len(tc.input)-split, n)
+				assert.Equal(tc.expected, buffer.String(), "split at %d", split)
+			}
+		})
+	}
+}
+
+func TestStripANSIWriter_Error(t *testing.T) {
+	assert := require.New(t)
+	w := StripANSIWriter(failingWriter{})
+	n, err := w.Write([]byte("\033[31m"))
+	assert.NoError(err)
+	assert.Equal(5, n)
+	n, err = w.Write([]byte("text"))
+	assert.EqualError(err, "disk full")
+	assert.Equal(0, n)
+}
+
+func TestStripANSIWriter_Formatter(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter(Detailed, nil)
+	formatter.DisableColors = true
+	entry := newBenchmarkEntry(formatter)
+	expected, err := formatter.Format(entry)
+	assert.NoError(err)
+
+	formatter.ForceColors = true
+	colored, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.NotEqual(string(expected), string(colored))
+
+	var buffer bytes.Buffer
+	_, err = StripANSIWriter(&buffer).Write(colored)
+	assert.NoError(err)
+	assert.Equal(string(expected), buffer.String())
+}
@@ -0,0 +1,299 @@
+package lcf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TemplateStyle selects the syntax of a template string like the style argument of Python's logging.Formatter.
+type TemplateStyle int
+
+const (
+	// PercentStyle templates look like "%-8[levelName]s %[message]s" or "%(levelname)-8s %(message)s". This is the
+	// style used by NewFormatter().
+	PercentStyle TemplateStyle = iota
+
+	// BraceStyle templates look like Python's str.format(): "{levelName:<8} {message}".
+	BraceStyle
+
+	// DollarStyle templates look like Python's string.Template: "$levelName ${message}".
+	DollarStyle
+)
+
+// Verbs accepted as the type of a brace style format spec (e.g. the "d" in "{process:>5d}").
+const braceTypes = "sdbcoxXeEfFgG"
+
+// pythonAttribute returns the lcf attribute name of a Python logging.LogRecord attribute name. Other names are returned
+// unchanged.
+func pythonAttribute(name string) string {
+	if attribute, ok := PythonAttributes[name]; ok {
+		return attribute
+	}
+	return name
+}
+
+// parseBrace parses a brace style template. If strict is false malformed fields are kept as plain text instead of
+// returning a *ParseError.
+func parseBrace(template string, strict bool) ([]Node, error) {
+	var nodes []Node
+	for i := 0; i < len(template); {
+		switch {
+		case strings.HasPrefix(template[i:], "{{"):
+			nodes = appendText(nodes, i, i+2, "{")
+			i += 2
+		case strings.HasPrefix(template[i:], "}}"):
+			nodes = appendText(nodes, i, i+2, "}")
+			i += 2
+		case template[i] == '{':
+			node, err := parseBraceField(template, i)
+			if err != nil {
+				if strict {
+					return nil, err
+				}
+				nodes = appendText(nodes, i, i+1, "{")
+				i++
+				continue
+			}
+			nodes = append(nodes, node)
+			i = node.End
+		case template[i] == '}':
+			if strict {
+				return nil, newParseError(template, i, "", "single '}' (use }} for a literal brace)")
+			}
+			nodes = appendText(nodes, i, i+1, "}")
+			i++
+		default:
+			end := strings.IndexAny(template[i:], "{}")
+			if end < 0 {
+				end = len(template)
+			} else {
+				end += i
+			}
+			nodes = appendText(nodes, i, end, template[i:end])
+			i = end
+		}
+	}
+	return nodes, nil
+}
+
+// parseBraceField parses one replacement field (e.g. "{levelName:<8}") starting at the left brace.
+func parseBraceField(template string, open int) (*AttributeNode, error) {
+	closing := strings.IndexAny(template[open+1:], "{}\n")
+	if closing < 0 || template[open+1+closing] != '}' {
+		return nil, newParseError(template, open, "", "unbalanced brace")
+	}
+	closing += open + 1
+	node := &AttributeNode{Pos: open, End: closing + 1, Width: -1, Precision: -1, namePos: open + 1}
+
+	// Split "name!conversion:spec".
+	field := template[open+1 : closing]
+	nameEnd := strings.IndexAny(field, "!:")
+	if nameEnd < 0 {
+		nameEnd = len(field)
+	}
+	if nameEnd == 0 {
+		return nil, newParseError(template, open, "", "empty attribute name")
+	}
+	name := field[:nameEnd]
+	node.Name = pythonAttribute(name)
+	var conversion byte
+	if nameEnd < len(field) && field[nameEnd] == '!' {
+		if nameEnd+1 >= len(field) || strings.IndexByte("rsa", field[nameEnd+1]) < 0 {
+			return nil, newParseError(template, open+2+nameEnd, name, "unsupported conversion for")
+		}
+		conversion = field[nameEnd+1]
+		nameEnd += 2
+	}
+	var spec string
+	specPos := open + 1 + nameEnd + 1
+	if nameEnd < len(field) {
+		if field[nameEnd] != ':' {
+			return nil, newParseError(template, open+1+nameEnd, name, "invalid format spec for")
+		}
+		spec = field[nameEnd+1:]
+	}
+
+	// Parse "[[fill]align][sign][#][0][width][.precision][type]".
+	var fill, align byte
+	p := 0
+	if len(spec) >= 2 && strings.IndexByte("<>=^", spec[1]) >= 0 {
+		fill, align = spec[0], spec[1]
+		p = 2
+	} else if len(spec) >= 1 && strings.IndexByte("<>=^", spec[0]) >= 0 {
+		align = spec[0]
+		p = 1
+	}
+	var flags []byte
+	if p < len(spec) && strings.IndexByte("+- ", spec[p]) >= 0 {
+		if spec[p] != '-' {
+			flags = append(flags, spec[p])
+		}
+		p++
+	}
+	if p < len(spec) && spec[p] == '#' {
+		flags = append(flags, '#')
+		p++
+	}
+	zero := false
+	if p < len(spec) && spec[p] == '0' {
+		zero = true
+		p++
+	}
+	if start := p; p < len(spec) && '0' <= spec[p] && spec[p] <= '9' {
+		for p < len(spec) && '0' <= spec[p] && spec[p] <= '9' {
+			p++
+		}
+		node.Width, _ = strconv.Atoi(spec[start:p])
+	}
+	if p < len(spec) && spec[p] == '.' {
+		start := p + 1
+		for p++; p < len(spec) && '0' <= spec[p] && spec[p] <= '9'; p++ {
+		}
+		node.Precision, _ = strconv.Atoi(spec[start:p])
+	}
+	if p < len(spec) && strings.IndexByte(braceTypes, spec[p]) >= 0 && (conversion == 0 || spec[p] == 's') {
+		node.Verb = rune(spec[p])
+		p++
+	}
+	if p < len(spec) {
+		return nil, newParseError(template, specPos+p, name, "invalid format spec for")
+	}
+
+	// Translate fill and alignment to fmt flags. Like Python strings are left aligned and numbers right aligned.
+	switch {
+	case fill == '0' && (align == '>' || align == '='), align == '=' && fill == 0 && zero:
+		zero = true
+	case align == '=':
+		return nil, newParseError(template, specPos, name, "'=' alignment requires zero padding for")
+	case fill != 0 && fill != ' ':
+		return nil, newParseError(template, specPos, name, "unsupported fill character for")
+	case align == '^' && node.Width >= 0:
+		// Centering is done by the center filter.
+		node.Options = append(node.Options, "center:"+strconv.Itoa(node.Width))
+	}
+	if conversion == 'r' || conversion == 'a' {
+		node.Verb = 'q'
+	} else if node.Verb == 0 {
+		node.Verb = 'v'
+	}
+	left := align == '<'
+	if align == 0 && !zero {
+		_, kind := lookupAppender(node.Name)
+		left = node.Verb == 's' || node.Verb == 'q' || node.Verb == 'v' && kind != 'd'
+	}
+	if left {
+		flags = append(flags, '-')
+	}
+	if zero {
+		flags = append(flags, '0')
+	}
+	node.Flags = string(flags)
+	node.directive = node.Directive()
+	return node, nil
+}
+
+// parseDollar parses a dollar style template. If strict is false malformed placeholders are kept as plain text instead
+// of returning a *ParseError.
+func parseDollar(template string, strict bool) ([]Node, error) {
+	var nodes []Node
+	for i := 0; i < len(template); {
+		if template[i] != '$' {
+			end := strings.IndexByte(template[i:], '$')
+			if end < 0 {
+				end = len(template)
+			} else {
+				end += i
+			}
+			nodes = appendText(nodes, i, end, template[i:end])
+			i = end
+			continue
+		}
+		if strings.HasPrefix(template[i:], "$$") {
+			nodes = appendText(nodes, i, i+2, "$")
+			i += 2
+			continue
+		}
+
+		// Find the placeholder's name.
+		var nameStart, nameEnd, end int
+		var err error
+		switch {
+		case strings.HasPrefix(template[i:], "${"):
+			nameStart = i + 2
+			nameEnd = strings.IndexAny(template[nameStart:], "{}$\n")
+			if nameEnd < 0 || template[nameStart+nameEnd] != '}' {
+				err = newParseError(template, i+1, "", "unbalanced brace")
+			} else if nameEnd == 0 {
+				err = newParseError(template, i+1, "", "empty attribute name")
+			}
+			nameEnd += nameStart
+			end = nameEnd + 1
+		case i+1 < len(template) && isIdentifierStart(template[i+1]):
+			nameStart = i + 1
+			for nameEnd = nameStart + 1; nameEnd < len(template) && isIdentifier(template[nameEnd]); nameEnd++ {
+			}
+			end = nameEnd
+		default:
+			err = newParseError(template, i, "", "stray $ (use $$ for a literal dollar sign)")
+		}
+		if err != nil {
+			if strict {
+				return nil, err
+			}
+			nodes = appendText(nodes, i, i+1, "$")
+			i++
+			continue
+		}
+
+		node := &AttributeNode{Pos: i, End: end, Width: -1, Precision: -1, Verb: 'v', namePos: nameStart}
+		node.Name = pythonAttribute(template[nameStart:nameEnd])
+		node.directive = node.Directive()
+		nodes = append(nodes, node)
+		i = end
+	}
+	return nodes, nil
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentifier(c byte) bool {
+	return isIdentifierStart(c) || '0' <= c && c <= '9'
+}
+
+// parseStyle parses a template string written in the given style.
+func parseStyle(template string, style TemplateStyle, strict bool) ([]Node, error) {
+	switch style {
+	case BraceStyle:
+		return parseBrace(template, strict)
+	case DollarStyle:
+		return parseDollar(template, strict)
+	}
+	return parse(template, strict)
+}
+
+// ParseStyle is like Parse but for template strings written in the given style.
+//
+// :param template: Pre-processed formatting template (e.g. "{levelName:<8} {message}\n").
+//
+// :param style: Syntax of the template string.
+func ParseStyle(template string, style TemplateStyle) ([]Node, error) {
+	return parseStyle(template, style, true)
+}
+
+// ParseTemplateStyle is like ParseTemplateE but for template strings written in the given style. Padding stays ANSI
+// aware like it does for PercentStyle templates.
+//
+// :param template: Pre-processed formatting template (e.g. "{levelName:<8} {message}\n").
+//
+// :param style: Syntax of the template string.
+//
+// :param custom: User-defined formatters evaluated before built-in formatters.
+func (f *CustomFormatter) ParseTemplateStyle(template string, style TemplateStyle, custom CustomHandlers) error {
+	nodes, err := parseStyle(template, style, true)
+	if err != nil {
+		return err
+	}
+	return f.compile(template, nodes, custom, style, true)
+}
@@ -0,0 +1,166 @@
+package lcf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStyleBrace(t *testing.T) {
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{"{message}", "%-v"},
+		{"{process}", "%v"},
+		{"{levelname:<8}", "%-8v"},
+		{"{levelName:>8s}", "%8s"},
+		{"{name:8.3}", "%-8.3v"},
+		{"{name!r:10}", "%-10q"},
+		{"{process:05d}", "%05d"},
+		{"{process:0>5}", "%05v"},
+		{"{process:+#x}", "%+#x"},
+		{"{relativeCreated: 4}", "% 4v"},
+		{"{message:^10}", "%10v"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			nodes, err := ParseStyle(tc.template, BraceStyle)
+			assert.NoError(err)
+			assert.Len(nodes, 1)
+			assert.Equal(tc.expected, nodes[0].(*AttributeNode).Directive())
+		})
+	}
+}
+
+func TestParseStyleBraceErrors(t *testing.T) {
+	testCases := []struct {
+		template string
+		offset   int
+		msg      string
+	}{
+		{"{message", 0, "unbalanced brace"},
+		{"message}", 7, "single '}' (use }} for a literal brace)"},
+		{"{}", 0, "empty attribute name"},
+		{"{message!x}", 9, "unsupported conversion for"},
+		{"{message!rs}", 10, "invalid format spec for"},
+		{"{message:10z}", 11, "invalid format spec for"},
+		{"{message:*^10}", 9, "unsupported fill character for"},
+		{"{message:*<10}", 9, "unsupported fill character for"},
+		{"{message:=10}", 9, "'=' alignment requires zero padding for"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			_, err := ParseStyle(tc.template, BraceStyle)
+			assert.Error(err)
+			assert.Equal(tc.offset, err.(*ParseError).Offset)
+			assert.Equal(tc.msg, err.(*ParseError).Msg)
+		})
+	}
+}
+
+func TestParseStyleDollar(t *testing.T) {
+	assert := require.New(t)
+
+	nodes, err := ParseStyle("$$$levelname: ${name}.$message_2$", DollarStyle)
+	assert.Error(err)
+	assert.Equal("stray $ (use $$ for a literal dollar sign)", err.(*ParseError).Msg)
+	assert.Equal(32, err.(*ParseError).Offset)
+
+	nodes, err = ParseStyle("$$$levelname: ${name}.$message_2", DollarStyle)
+	assert.NoError(err)
+	assert.Len(nodes, 6)
+	assert.Equal(&TextNode{Pos: 0, End: 2, Text: "$"}, nodes[0])
+	assert.Equal("levelName", nodes[1].(*AttributeNode).Name)
+	assert.Equal(&TextNode{Pos: 12, End: 14, Text: ": "}, nodes[2])
+	assert.Equal("name", nodes[3].(*AttributeNode).Name)
+	assert.Equal("message_2", nodes[5].(*AttributeNode).Name)
+
+	_, err = ParseStyle("${name", DollarStyle)
+	assert.Equal("unbalanced brace", err.(*ParseError).Msg)
+	_, err = ParseStyle("${}", DollarStyle)
+	assert.Equal("empty attribute name", err.(*ParseError).Msg)
+}
+
+func TestNewFormatterStyle(t *testing.T) {
+	testCases := []struct {
+		template string
+		style    TemplateStyle
+	}{
+		{"%-9[levelName]s|%5[process]d|%[message]s%%\n", PercentStyle},
+		{"{levelName:<9}|{process:5}|{message}%\n", BraceStyle},
+		{"{levelname:9s}|{process:>5d}|{message}%\n", BraceStyle},
+		{"${levelName}         |$process|$message%\n", DollarStyle},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			formatter, err := NewFormatterStyle(tc.template, tc.style, nil)
+			assert.NoError(err)
+			formatter.ForceColors = true
+			entry := newBenchmarkEntry(formatter)
+			actual, err := formatter.Format(entry)
+			assert.NoError(err)
+
+			expected := "\033[33mWARNING\033[0m  |" + sprintfPid("%5d") + "|Sample warn 2.%\n"
+			if tc.style == DollarStyle {
+				expected = "\033[33mWARNING\033[0m         |" + sprintfPid("%d") + "|Sample warn 2.%\n"
+			}
+			assert.Equal(expected, string(actual))
+			assert.Len(formatter.Handlers, 3)
+		})
+	}
+
+	_, err := NewFormatterStyle("{levelName:<9} {mesage}", BraceStyle, nil)
+	assert := require.New(t)
+	assert.EqualError(err, "lcf: unknown attribute \"mesage\" at line 1 column 17 (offset 16)\n"+
+		"\t{levelName:<9} {mesage}\n"+
+		"\t                ^")
+}
+
+func TestNewFormatterStyleFieldReference(t *testing.T) {
+	testCases := []struct {
+		template string
+		style    TemplateStyle
+	}{
+		{"%4[.c]v|%[field:a]s|%[fields]s", PercentStyle},
+		{"{.c:>4}|{.a}|{fields}", BraceStyle},
+		{"${.c}|${field:a}|$fields", DollarStyle},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			formatter, err := NewFormatterStyle(tc.template, tc.style, nil)
+			assert.NoError(err)
+			formatter.DisableColors = true
+			actual, err := formatter.Format(newBenchmarkEntry(formatter))
+			assert.NoError(err)
+
+			expected := "  10|b| name=LogMsgs"
+			if tc.style == DollarStyle {
+				expected = "10|b| name=LogMsgs"
+			}
+			assert.Equal(expected, string(actual))
+		})
+	}
+}
+
+func TestNewFormatterStyleCenter(t *testing.T) {
+	assert := require.New(t)
+	formatter, err := NewFormatterStyle("[{levelName:^11}][{message:^4}]", BraceStyle, nil)
+	assert.NoError(err)
+	formatter.ForceColors = true
+	actual, err := formatter.Format(newBenchmarkEntry(formatter))
+	assert.NoError(err)
+	assert.Equal("[  \033[33mWARNING\033[0m  ][Sample warn 2.]", string(actual))
+
+	nodes, err := ParseStyle("{message:^10}", BraceStyle)
+	assert.NoError(err)
+	assert.Equal([]string{"center:10"}, nodes[0].(*AttributeNode).Options)
+}
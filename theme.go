@@ -0,0 +1,115 @@
+package lcf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ThemeEnv is the environment variable naming the theme formatters start with (e.g. LCF_THEME=solarized-dark).
+const ThemeEnv = "LCF_THEME"
+
+// Theme is a set of colors for every colored part of a log line. AnsiReset leaves a part uncolored, except for
+// FieldKey where it means the entry's level color.
+type Theme struct {
+	// Colors of the level names and of field keys without a FieldKey color.
+	Trace Style
+	Debug Style
+	Info  Style
+	Warn  Style
+	Error Style
+	Fatal Style
+	Panic Style
+
+	// Colors of the keys and values in %[fields]s.
+	FieldKey   Style
+	FieldValue Style
+
+	// Colors of %[ascTime]s, %[name]s, and the caller attributes %[fileName]s, %[funcName]s, %[module]s and
+	// %[pathName]s.
+	Timestamp Style
+	Name      Style
+	Caller    Style
+}
+
+// Solarized colors (https://ethanschoonover.com/solarized/).
+var (
+	solarizedBase01  = RGB(0x58, 0x6E, 0x75)
+	solarizedBase1   = RGB(0x93, 0xA1, 0xA1)
+	solarizedYellow  = RGB(0xB5, 0x89, 0x00)
+	solarizedOrange  = RGB(0xCB, 0x4B, 0x16)
+	solarizedRed     = RGB(0xDC, 0x32, 0x2F)
+	solarizedMagenta = RGB(0xD3, 0x36, 0x82)
+	solarizedViolet  = RGB(0x6C, 0x71, 0xC4)
+	solarizedBlue    = RGB(0x26, 0x8B, 0xD2)
+	solarizedCyan    = RGB(0x2A, 0xA1, 0x98)
+	solarizedGreen   = RGB(0x85, 0x99, 0x00)
+)
+
+// Bundled themes by name.
+var themes = map[string]Theme{
+	"default": {
+		Trace: AnsiHiBlack, Debug: AnsiCyan, Info: AnsiGreen, Warn: AnsiYellow, Error: AnsiRed,
+		Fatal: AnsiMagenta, Panic: AnsiMagenta,
+	},
+	"solarized-dark": {
+		Trace: solarizedBase01, Debug: solarizedCyan, Info: solarizedGreen, Warn: solarizedYellow, Error: solarizedRed,
+		Fatal: solarizedMagenta.Bold(), Panic: solarizedMagenta.Bold(),
+		FieldKey: solarizedBlue, Timestamp: solarizedBase01, Name: solarizedViolet, Caller: solarizedBase01,
+	},
+	"solarized-light": {
+		Trace: solarizedBase1, Debug: solarizedCyan, Info: solarizedGreen, Warn: solarizedOrange, Error: solarizedRed,
+		Fatal: solarizedMagenta.Bold(), Panic: solarizedMagenta.Bold(),
+		FieldKey: solarizedBlue, Timestamp: solarizedBase1, Name: solarizedViolet, Caller: solarizedBase1,
+	},
+	"monochrome": {
+		Trace: StyleDim.Italic(), Debug: StyleDim, Warn: StyleBold, Error: StyleBold.Underline(),
+		Fatal: StyleBold.Underline(), Panic: StyleBold.Underline(),
+		FieldKey: StyleUnderline, Timestamp: StyleDim, Caller: StyleDim,
+	},
+	"high-contrast": {
+		Trace: Style(AnsiBlue).Bold(), Debug: Style(AnsiHiCyan).Bold(), Info: Style(AnsiHiGreen).Bold(),
+		Warn: Style(AnsiBlack).On(AnsiHiYellow).Bold(), Error: Style(AnsiHiWhite).On(AnsiRed).Bold(),
+		Fatal: Style(AnsiHiWhite).On(AnsiMagenta).Bold(), Panic: Style(AnsiHiWhite).On(AnsiMagenta).Bold(),
+		FieldKey: Style(AnsiHiBlue).Bold(), Name: StyleBold,
+	},
+}
+
+// ThemeByName returns one of the bundled themes: "default", "solarized-dark", "solarized-light", "monochrome" or
+// "high-contrast". Names are case insensitive.
+//
+// :param name: Name of the theme.
+func ThemeByName(name string) (Theme, error) {
+	theme, ok := themes[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Theme{}, fmt.Errorf("lcf: unknown theme %q", name)
+	}
+	return theme, nil
+}
+
+// SetTheme sets all of the formatter's Color* fields from a theme.
+//
+// :param theme: Colors to use.
+func (f *CustomFormatter) SetTheme(theme Theme) {
+	f.ColorTrace = theme.Trace
+	f.ColorDebug = theme.Debug
+	f.ColorInfo = theme.Info
+	f.ColorWarn = theme.Warn
+	f.ColorError = theme.Error
+	f.ColorFatal = theme.Fatal
+	f.ColorPanic = theme.Panic
+	f.ColorFieldKey = theme.FieldKey
+	f.ColorFieldValue = theme.FieldValue
+	f.ColorTimestamp = theme.Timestamp
+	f.ColorName = theme.Name
+	f.ColorCaller = theme.Caller
+}
+
+// envTheme returns the theme named by the LCF_THEME environment variable, or the default theme if it is unset or
+// unknown.
+func envTheme() Theme {
+	if theme, err := ThemeByName(os.Getenv(ThemeEnv)); err == nil {
+		return theme
+	}
+	return themes["default"]
+}
@@ -0,0 +1,124 @@
+package lcf
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThemeByName(t *testing.T) {
+	for _, name := range []string{"default", "solarized-dark", "solarized-light", "monochrome", "high-contrast"} {
+		t.Run(name, func(t *testing.T) {
+			assert := require.New(t)
+			theme, err := ThemeByName(name)
+			assert.NoError(err)
+			assert.NotEqual(Theme{}, theme)
+		})
+	}
+
+	assert := require.New(t)
+	theme, err := ThemeByName(" Solarized-Dark ")
+	assert.NoError(err)
+	assert.Equal(themes["solarized-dark"], theme)
+
+	_, err = ThemeByName("solarized")
+	assert.EqualError(err, `lcf: unknown theme "solarized"`)
+}
+
+func TestThemeEnv(t *testing.T) {
+	testCases := []struct {
+		env      string
+		expected string
+	}{
+		{"", "default"},
+		{"monochrome", "monochrome"},
+		{"HIGH-CONTRAST", "high-contrast"},
+		{"bogus", "default"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.env, func(t *testing.T) {
+			assert := require.New(t)
+			defer setenv(ThemeEnv, tc.env)()
+			formatter := NewFormatter(Basic, nil)
+			expected := themes[tc.expected]
+			assert.Equal(expected.Warn, formatter.ColorWarn)
+			assert.Equal(expected.Debug, formatter.ColorDebug)
+			assert.Equal(expected.FieldKey, formatter.ColorFieldKey)
+			assert.Equal(expected.Timestamp, formatter.ColorTimestamp)
+		})
+	}
+}
+
+func TestCustomFormatter_SetTheme(t *testing.T) {
+	theme := Theme{
+		Warn:       AnsiYellow,
+		FieldKey:   AnsiBlue,
+		FieldValue: StyleDim,
+		Timestamp:  AnsiHiBlack,
+		Name:       AnsiMagenta,
+		Caller:     AnsiCyan,
+	}
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{"%[ascTime]s", "\033[90m2016-10-30 19:12:17.149\033[0m"},
+		{"%-10[name]s|", "\033[35mLogMsgs\033[0m   |"},
+		{"%[levelName]s %[name]s%[fields]s", "\033[33mWARNING\033[0m \033[35mLogMsgs\033[0m \033[34ma\033[0m=\033[2mb\033[0m \033[34mc\033[0m=\033[2m10\033[0m"},
+		{"%[name]q", `"\x1b[35mLogMsgs\x1b[0m"`},
+		{"%[funcName]s", "\033[36mfunc1\033[0m"},
+		{"%{(%[.missing]s)%}%[fields]s", " \033[34ma\033[0m=\033[2mb\033[0m \033[34mc\033[0m=\033[2m10\033[0m \033[34mname\033[0m=\033[2mLogMsgs\033[0m"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			assert := require.New(t)
+			formatter := NewFormatter(tc.template, nil)
+			formatter.SetTheme(theme)
+			formatter.ForceColors = true
+			actual, err := formatter.Format(newBenchmarkEntry(formatter))
+			assert.NoError(err)
+			assert.Equal(tc.expected, string(actual))
+		})
+	}
+
+	assert := require.New(t)
+	formatter := NewFormatter("%[ascTime]s %[name]s%[fields]s", nil)
+	formatter.SetTheme(theme)
+	formatter.ForceColors = true
+	value, err := HandlerAscTime(newBenchmarkEntry(formatter), formatter)
+	assert.NoError(err)
+	assert.Equal("\033[90m2016-10-30 19:12:17.149\033[0m", value)
+
+	formatter.ForceColors = false
+	formatter.DisableColors = true
+	actual, err := formatter.Format(newBenchmarkEntry(formatter))
+	assert.NoError(err)
+	assert.Equal("2016-10-30 19:12:17.149 LogMsgs a=b c=10", string(actual))
+
+	// Field keys use the level color without a FieldKey color.
+	formatter.ForceColors = true
+	formatter.SetTheme(Theme{Error: AnsiRed})
+	entry := newBenchmarkEntry(formatter)
+	entry.Level = logrus.ErrorLevel
+	actual, err = formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("2016-10-30 19:12:17.149 LogMsgs \033[31ma\033[0m=b \033[31mc\033[0m=10", string(actual))
+}
+
+func TestThemeSolarizedDark(t *testing.T) {
+	assert := require.New(t)
+	theme, err := ThemeByName("solarized-dark")
+	assert.NoError(err)
+	formatter := NewFormatter("%[ascTime]s %[levelName]s %[name]s:%[fields]s", nil)
+	formatter.SetTheme(theme)
+	formatter.ForceColors = true
+	formatter.Palette = PaletteTrueColor
+	entry := newBenchmarkEntry(formatter)
+	delete(entry.Data, "c")
+
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal("\033[38;2;88;110;117m2016-10-30 19:12:17.149\033[0m \033[38;2;181;137;0mWARNING\033[0m "+
+		"\033[38;2;108;113;196mLogMsgs\033[0m: \033[38;2;38;139;210ma\033[0m=b", string(actual))
+}
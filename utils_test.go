@@ -2,12 +2,25 @@ package lcf
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
+	"testing"
 
 	"github.com/sirupsen/logrus"
 )
 
+// TestMain clears environment variables read by NewFormatter so tests do not depend on the developer's terminal or
+// theme.
+func TestMain(m *testing.M) {
+	for _, key := range []string{
+		ThemeEnv, "NO_COLOR", "CLICOLOR", "CLICOLOR_FORCE", "FORCE_COLOR", "COLORTERM", "WT_SESSION", "TERM",
+	} {
+		os.Unsetenv(key)
+	}
+	os.Exit(m.Run())
+}
+
 // WithCapSys temporarily redirects stdout/stderr pipes to capture the output while the function runs. Returns them as
 // strings.
 func WithCapSys(function func()) (string, string, error) {
@@ -65,3 +78,8 @@ func LogMsgs() {
 	logrus.Error("Sample error 1.")
 	logrus.WithFields(logrus.Fields{"name": CallerName(1), "a": "b", "c": 10}).Error("Sample error 2.")
 }
+
+// Format the current PID with fmt.Sprintf().
+func sprintfPid(format string) string {
+	return fmt.Sprintf(format, os.Getpid())
+}
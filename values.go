@@ -0,0 +1,179 @@
+package lcf
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ValueRenderer writes a field value shown by %[fields]s or a field-reference attribute such as %[.request_id]s. It
+// appends the value as text to b and returns the extended buffer. Text written to %[fields]s is quoted afterwards
+// according to FieldQuoting.
+//
+// Renderers are picked by field key (CustomFormatter.FieldRenderers), then by the value's Go type
+// (CustomFormatter.TypeRenderers). Without one time.Time values are written with RenderTime, []byte values with
+// RenderBytesHex and json.Marshaler values that are not errors or fmt.Stringers with RenderJSON. Other values are
+// written like fmt's %v verb.
+type ValueRenderer func(b []byte, value interface{}, formatter *CustomFormatter) []byte
+
+// SetTypeRenderer sets the ValueRenderer of values with the same Go type as example (e.g. time.Duration(0) or
+// []byte(nil)) in TypeRenderers. Like the other fields it must not be called while entries are formatted.
+//
+// :param example: Value of the type the renderer is used for.
+//
+// :param renderer: Writes values of the type. Nil removes the type's renderer.
+func (f *CustomFormatter) SetTypeRenderer(example interface{}, renderer ValueRenderer) {
+	if renderer == nil {
+		delete(f.TypeRenderers, reflect.TypeOf(example))
+		return
+	}
+	if f.TypeRenderers == nil {
+		f.TypeRenderers = make(map[reflect.Type]ValueRenderer)
+	}
+	f.TypeRenderers[reflect.TypeOf(example)] = renderer
+}
+
+// renderer returns the ValueRenderer of a field. Returns nil if the value is written like fmt's %v verb.
+func (f *CustomFormatter) renderer(key string, value interface{}) ValueRenderer {
+	if renderer, ok := f.FieldRenderers[key]; ok {
+		return renderer
+	}
+	if len(f.TypeRenderers) > 0 {
+		if renderer, ok := f.TypeRenderers[reflect.TypeOf(value)]; ok {
+			return renderer
+		}
+	}
+	switch value.(type) {
+	case time.Time:
+		return RenderTime
+	case []byte:
+		return RenderBytesHex
+	case error, fmt.Stringer:
+		return nil
+	case json.Marshaler:
+		return RenderJSON
+	}
+	return nil
+}
+
+// appendRendered writes a field value to the render buffer with its ValueRenderer.
+func (f *CustomFormatter) appendRendered(s *renderState, key string, value interface{}) {
+	renderer := f.renderer(key, value)
+	if renderer == nil {
+		appendValue(s, value)
+		return
+	}
+	s.scratch = renderer(s.scratch[:0], value, f)
+	s.buffer.Write(s.scratch)
+}
+
+// appendDefault appends a value like fmt's %v verb. Used by renderers for values of other types.
+func appendDefault(b []byte, value interface{}) []byte {
+	return append(b, fmt.Sprint(value)...)
+}
+
+// RenderDuration writes a time.Duration like "1h2m3.5s".
+func RenderDuration(b []byte, value interface{}, _ *CustomFormatter) []byte {
+	if d, ok := value.(time.Duration); ok {
+		return append(b, d.String()...)
+	}
+	return appendDefault(b, value)
+}
+
+// RenderDurationSeconds writes a time.Duration as a number of seconds like "3723.5".
+func RenderDurationSeconds(b []byte, value interface{}, _ *CustomFormatter) []byte {
+	if d, ok := value.(time.Duration); ok {
+		return strconv.AppendFloat(b, d.Seconds(), 'f', -1, 64)
+	}
+	return appendDefault(b, value)
+}
+
+// RenderTime writes a time.Time formatted by CustomFormatter.TimestampFormat.
+func RenderTime(b []byte, value interface{}, formatter *CustomFormatter) []byte {
+	if t, ok := value.(time.Time); ok {
+		return t.AppendFormat(b, formatter.TimestampFormat)
+	}
+	return appendDefault(b, value)
+}
+
+// RenderBytesHex writes a []byte as lower case hexadecimal digits like "cafe01".
+func RenderBytesHex(b []byte, value interface{}, _ *CustomFormatter) []byte {
+	p, ok := value.([]byte)
+	if !ok {
+		return appendDefault(b, value)
+	}
+	const digits = "0123456789abcdef"
+	for _, c := range p {
+		b = append(b, digits[c>>4], digits[c&0xF])
+	}
+	return b
+}
+
+// RenderBytesBase64 writes a []byte with standard padded base64 encoding like "yv4B".
+func RenderBytesBase64(b []byte, value interface{}, _ *CustomFormatter) []byte {
+	p, ok := value.([]byte)
+	if !ok {
+		return appendDefault(b, value)
+	}
+	return append(b, base64.StdEncoding.EncodeToString(p)...)
+}
+
+// RenderBytesLength writes the length of a []byte like "3 bytes" instead of its contents.
+func RenderBytesLength(b []byte, value interface{}, _ *CustomFormatter) []byte {
+	p, ok := value.([]byte)
+	if !ok {
+		return appendDefault(b, value)
+	}
+	b = strconv.AppendInt(b, int64(len(p)), 10)
+	return append(b, " bytes"...)
+}
+
+// RenderError writes the message of an error.
+func RenderError(b []byte, value interface{}, _ *CustomFormatter) []byte {
+	if err, ok := value.(error); ok && !isNilPointer(value) {
+		return append(b, err.Error()...)
+	}
+	return appendDefault(b, value)
+}
+
+// RenderStringer writes the result of a fmt.Stringer's String method.
+func RenderStringer(b []byte, value interface{}, _ *CustomFormatter) []byte {
+	if stringer, ok := value.(fmt.Stringer); ok && !isNilPointer(value) {
+		return append(b, stringer.String()...)
+	}
+	return appendDefault(b, value)
+}
+
+// RenderJSON writes a value encoded as JSON, using MarshalJSON of json.Marshalers. Useful for structs and maps. Values
+// that cannot be encoded are written like fmt's %v verb.
+func RenderJSON(b []byte, value interface{}, _ *CustomFormatter) []byte {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return appendDefault(b, value)
+	}
+	return append(b, encoded...)
+}
+
+// isNilPointer returns true if a value is a nil pointer, whose methods may panic.
+func isNilPointer(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// renderedField returns a field's value for handlers: the text written by its ValueRenderer, or the value itself if
+// it is written like fmt's %v verb so directives such as %5d still apply.
+func renderedField(formatter *CustomFormatter, entry *logrus.Entry, key string) interface{} {
+	value, ok := entry.Data[key]
+	if !ok {
+		return ""
+	}
+	if renderer := formatter.renderer(key, value); renderer != nil {
+		return string(renderer(nil, value, formatter))
+	}
+	return value
+}
@@ -0,0 +1,150 @@
+package lcf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonPoint struct{ X, Y int }
+
+func (p jsonPoint) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`[%d,%d]`, p.X, p.Y)), nil
+}
+
+type testStringer struct{ name string }
+
+func (s *testStringer) String() string { return "<" + s.name + ">" }
+
+func TestValueRenderers(t *testing.T) {
+	formatter := NewFormatter("", nil)
+	formatter.TimestampFormat = time.RFC3339
+	date := time.Date(2016, 10, 30, 19, 12, 17, 149000000, time.UTC)
+	bytes := []byte{0xCA, 0xFE, 0x01}
+	var nilStringer *testStringer
+
+	testCases := []struct {
+		renderer ValueRenderer
+		value    interface{}
+		expected string
+	}{
+		{RenderDuration, 3723500 * time.Millisecond, "1h2m3.5s"},
+		{RenderDurationSeconds, 3723500 * time.Millisecond, "3723.5"},
+		{RenderDurationSeconds, 5, "5"},
+		{RenderTime, date, "2016-10-30T19:12:17Z"},
+		{RenderBytesHex, bytes, "cafe01"},
+		{RenderBytesHex, []byte{}, ""},
+		{RenderBytesBase64, bytes, "yv4B"},
+		{RenderBytesLength, bytes, "3 bytes"},
+		{RenderBytesLength, "abc", "abc"},
+		{RenderError, errors.New("oops"), "oops"},
+		{RenderStringer, &testStringer{"x"}, "<x>"},
+		{RenderStringer, nilStringer, "<nil>"},
+		{RenderJSON, map[string]int{"b": 2, "a": 1}, `{"a":1,"b":2}`},
+		{RenderJSON, struct{ Name string }{"n"}, `{"Name":"n"}`},
+		{RenderJSON, jsonPoint{1, 2}, "[1,2]"},
+		{RenderJSON, math.Inf(1), "+Inf"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%T %v", tc.value, tc.expected), func(t *testing.T) {
+			assert := require.New(t)
+			actual := string(tc.renderer([]byte("k="), tc.value, formatter))
+			assert.Equal("k="+tc.expected, actual)
+		})
+	}
+}
+
+func TestCustomFormatter_ValueRenderers(t *testing.T) {
+	date := time.Date(2016, 10, 30, 19, 12, 17, 149000000, time.UTC)
+	fields := logrus.Fields{
+		"bytes":    []byte("hi"),
+		"duration": 1500 * time.Millisecond,
+		"error":    errors.New("file not found"),
+		"ip":       net.IPv4(10, 0, 0, 1),
+		"point":    jsonPoint{3, 4},
+		"time":     date,
+		"user":     map[string]string{"id": "u1"},
+	}
+
+	testCases := []struct {
+		name     string
+		setup    func(*CustomFormatter)
+		expected string
+	}{
+		{
+			"defaults",
+			func(*CustomFormatter) {},
+			` bytes=6869 duration=1.5s error="file not found" ip=10.0.0.1 point=[3,4] time="2016-10-30 19:12:17.149"` +
+				" user=map[id:u1]",
+		},
+		{
+			"types and keys",
+			func(f *CustomFormatter) {
+				f.TimestampFormat = time.RFC3339
+				f.SetTypeRenderer([]byte(nil), RenderBytesBase64)
+				f.SetTypeRenderer(time.Duration(0), RenderDurationSeconds)
+				f.FieldRenderers = map[string]ValueRenderer{"user": RenderJSON}
+			},
+			` bytes="aGk=" duration=1.5 error="file not found" ip=10.0.0.1 point=[3,4] time=2016-10-30T19:12:17Z` +
+				` user="{\"id\":\"u1\"}"`,
+		},
+		{
+			"removed type renderer",
+			func(f *CustomFormatter) {
+				f.SetTypeRenderer([]byte(nil), RenderBytesLength)
+				f.SetTypeRenderer(time.Time{}, func(b []byte, _ interface{}, _ *CustomFormatter) []byte {
+					return append(b, "then"...)
+				})
+				f.SetTypeRenderer([]byte(nil), nil)
+			},
+			` bytes=6869 duration=1.5s error="file not found" ip=10.0.0.1 point=[3,4] time=then user=map[id:u1]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+			formatter := NewFormatter("%[fields]s", nil)
+			formatter.DisableColors = true
+			tc.setup(formatter)
+			entry := logrus.NewEntry(logrus.New()).WithFields(fields)
+
+			actual, err := formatter.Format(entry)
+			assert.NoError(err)
+			assert.Equal(tc.expected, string(actual))
+		})
+	}
+}
+
+func TestCustomFormatter_ValueRenderersFieldReference(t *testing.T) {
+	assert := require.New(t)
+	formatter := NewFormatter("%[.time]s|%-8[field:bytes]s|%[.user]s|%03[.n]d|%[.missing]s|%[fields]s", nil)
+	formatter.DisableColors = true
+	formatter.FieldRenderers = map[string]ValueRenderer{"user": RenderJSON}
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"time":  time.Date(2016, 10, 30, 19, 12, 17, 149000000, time.UTC),
+		"bytes": []byte{1, 2},
+		"user":  map[string]string{"id": "u 1"},
+		"n":     7,
+		"other": []byte{3},
+	})
+
+	actual, err := formatter.Format(entry)
+	assert.NoError(err)
+	assert.Equal(`2016-10-30 19:12:17.149|0102    |{"id":"u 1"}|007|| other=03`, string(actual))
+
+	// Handlers called directly render values too.
+	value, err := HandlerField("user")(entry, formatter)
+	assert.NoError(err)
+	assert.Equal(`{"id":"u 1"}`, value)
+	value, err = HandlerField("n")(entry, formatter)
+	assert.NoError(err)
+	assert.Equal(7, value)
+}
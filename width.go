@@ -0,0 +1,113 @@
+package lcf
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ranges of East Asian wide and fullwidth characters and emoji shown as two columns by terminals.
+var wideRanges = [...]struct{ lo, hi rune }{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC}, {0x23F0, 0x23F0}, {0x23F3, 0x23F3},
+	{0x25FD, 0x25FE}, {0x2614, 0x2615}, {0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE}, {0x26D4, 0x26D4}, {0x26EA, 0x26EA},
+	{0x26F2, 0x26F3}, {0x26F5, 0x26F5}, {0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755}, {0x2757, 0x2757}, {0x2795, 0x2797},
+	{0x27B0, 0x27B0}, {0x27BF, 0x27BF}, {0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xA960, 0xA97F}, {0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF}, {0xFE10, 0xFE19}, {0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x16FE0, 0x16FE4},
+	{0x17000, 0x18AFF}, {0x1B000, 0x1B2FF}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF}, {0x1F18E, 0x1F18E},
+	{0x1F191, 0x1F19A}, {0x1F1E6, 0x1F1FF}, {0x1F200, 0x1F202}, {0x1F210, 0x1F23B}, {0x1F240, 0x1F248},
+	{0x1F250, 0x1F251}, {0x1F260, 0x1F265}, {0x1F300, 0x1F320}, {0x1F32D, 0x1F335}, {0x1F337, 0x1F37C},
+	{0x1F37E, 0x1F393}, {0x1F3A0, 0x1F3CA}, {0x1F3CF, 0x1F3D3}, {0x1F3E0, 0x1F3F0}, {0x1F3F4, 0x1F3F4},
+	{0x1F3F8, 0x1F43E}, {0x1F440, 0x1F440}, {0x1F442, 0x1F4FC}, {0x1F4FF, 0x1F53D}, {0x1F54B, 0x1F54E},
+	{0x1F550, 0x1F567}, {0x1F57A, 0x1F57A}, {0x1F595, 0x1F596}, {0x1F5A4, 0x1F5A4}, {0x1F5FB, 0x1F64F},
+	{0x1F680, 0x1F6C5}, {0x1F6CC, 0x1F6CC}, {0x1F6D0, 0x1F6D2}, {0x1F6D5, 0x1F6D7}, {0x1F6EB, 0x1F6EC},
+	{0x1F6F4, 0x1F6FC}, {0x1F7E0, 0x1F7EB}, {0x1F90C, 0x1F93A}, {0x1F93C, 0x1F945}, {0x1F947, 0x1F978},
+	{0x1F97A, 0x1F9CB}, {0x1F9CD, 0x1F9FF}, {0x1FA70, 0x1FA74}, {0x1FA78, 0x1FA7A}, {0x1FA80, 0x1FA86},
+	{0x1FA90, 0x1FAA8}, {0x1FAB0, 0x1FAB6}, {0x1FAC0, 0x1FAC2}, {0x1FAD0, 0x1FAD6}, {0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+const (
+	zeroWidthJoiner = 0x200D
+	regionalFirst   = 0x1F1E6 // Regional indicator symbol letter A. Pairs of them are flags.
+	regionalLast    = 0x1F1FF
+	modifierFirst   = 0x1F3FB // Emoji skin tone modifiers.
+	modifierLast    = 0x1F3FF
+)
+
+// runeWidth returns the number of terminal columns of a rune on its own like wcwidth(): 0 for control characters,
+// combining marks and other zero-width characters, 2 for East Asian wide and fullwidth characters and emoji, and 1 for
+// everything else.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || 0x7F <= r && r < 0xA0:
+		return 0
+	case r < 0x300:
+		return 1
+	case 0x1160 <= r && r <= 0x11FF, r == zeroWidthJoiner: // Hangul medial vowels and final consonants.
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	i := sort.Search(len(wideRanges), func(i int) bool { return wideRanges[i].hi >= r })
+	if i < len(wideRanges) && wideRanges[i].lo <= r {
+		return 2
+	}
+	return 1
+}
+
+// widthCounter measures the width of text one rune at a time, keeping track of grapheme clusters that are shown as a
+// single character: characters joined by zero width joiners, emoji with skin tone modifiers, and flags made of two
+// regional indicators.
+type widthCounter struct {
+	width    int
+	started  bool // At least one rune was counted.
+	joined   bool // The previous rune was a zero width joiner.
+	regional bool // The previous rune was the first regional indicator of a flag.
+}
+
+// add counts one rune.
+func (c *widthCounter) add(r rune) {
+	switch {
+	case c.joined:
+		c.joined = false
+	case r == zeroWidthJoiner:
+		c.joined = c.started
+	case modifierFirst <= r && r <= modifierLast && c.started:
+	case regionalFirst <= r && r <= regionalLast:
+		if !c.regional {
+			c.width += 2
+		}
+		c.regional = !c.regional
+		c.started = true
+		return
+	default:
+		c.width += runeWidth(r)
+	}
+	c.regional = false
+	c.started = true
+}
+
+// displayWidth returns the number of terminal columns text takes. ANSI color sequences are skipped if ansi is true.
+func displayWidth(b []byte, ansi bool) int {
+	var c widthCounter
+	for i := 0; i < len(b); {
+		if ansi {
+			if n := ansiSequenceLen(b[i:]); n > 0 {
+				i += n
+				continue
+			}
+		}
+		if b[i] < utf8.RuneSelf {
+			c.add(rune(b[i]))
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(b[i:])
+		c.add(r)
+		i += size
+	}
+	return c.width
+}
@@ -0,0 +1,54 @@
+package lcf
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuneWidth(t *testing.T) {
+	testCases := map[rune]int{
+		'a':      1,
+		'\n':     0,
+		'\u00e9': 1,
+		'\u0301': 0,
+		'\u200d': 0,
+		'\ufeff': 0,
+		'日':      2,
+		'\u3000': 2,
+		'\uff61': 1, // Halfwidth katakana punctuation.
+		'🚀':      2,
+		'\u2605': 1, // Ambiguous width characters are narrow.
+	}
+	for r, expected := range testCases {
+		t.Run(fmt.Sprintf("%U", r), func(t *testing.T) {
+			assert := require.New(t)
+			assert.Equal(expected, runeWidth(r))
+		})
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	testCases := map[string]int{
+		"abc":                3,
+		"日本語":                6,
+		"한국어":                6,
+		"ｆｕｌｌ":               8,
+		"e\u0301":            1, // Combining acute accent.
+		"\u1100\u1161\u11a8": 2, // Hangul syllable from conjoining jamo.
+		"👍":                  2,
+		"👍🏽":                 2, // Skin tone modifier.
+		"👩\u200d💻":           2, // Zero width joiner sequence.
+		"🇯🇵🇰🇷":               4, // Two flags.
+		"a\u200bb":           2, // Zero width space.
+		"tab\there":          7,
+		"\033[31m日本\033[0m語": 6,
+	}
+	for input, expected := range testCases {
+		t.Run(fmt.Sprintf("%q", input), func(t *testing.T) {
+			assert := require.New(t)
+			assert.Equal(expected, visibleWidth([]byte(input)))
+		})
+	}
+}